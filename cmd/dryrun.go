@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"orchestra/scheduler"
+	"orchestra/worker"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+func runDryRun(args []string) {
+	fs := flag.NewFlagSet("dry-run", flag.ExitOnError)
+	managerAddr := fs.String("manager", "localhost:5555", "Address (host:port) of the manager to ask")
+	file := fs.String("file", "-", "File holding the task event to explain, as it would be posted to /tasks; - reads standard input")
+	asJSON := fs.Bool("json", false, "Print the explanation as JSON rather than as a table")
+	fs.Parse(args)
+
+	in := os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			log.Fatalf("Error opening the task event: %v", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	body, err := io.ReadAll(in)
+	if err != nil {
+		log.Fatalf("Error reading the task event: %v", err)
+	}
+
+	resp, err := http.Post(fmt.Sprintf("http://%s/tasks/dry-run", *managerAddr), "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Fatalf("Error contacting manager %s: %v", *managerAddr, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		e := worker.ErrorResponse{}
+		json.NewDecoder(resp.Body).Decode(&e)
+		log.Fatalf("Manager %s refused the dry run with status %d: %s", *managerAddr, resp.StatusCode, e.Message)
+	}
+
+	var e scheduler.Explanation
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		log.Fatalf("Error decoding the explanation: %v", err)
+	}
+	if *asJSON {
+		out := json.NewEncoder(os.Stdout)
+		out.SetIndent("", "  ")
+		out.Encode(e)
+		return
+	}
+	printExplanation(os.Stdout, e)
+}
+
+// printExplanation writes a table with a row per node, followed by the node the task would go to.
+func printExplanation(w io.Writer, e scheduler.Explanation) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NODE\tCANDIDATE\tSCORE\tSCORES\tREASON")
+	for _, n := range e.Nodes {
+		if !n.Candidate {
+			fmt.Fprintf(tw, "%s\tno\t-\t-\t%s: %s\n", n.Name, n.Filter, n.Reason)
+			continue
+		}
+
+		names := make([]string, 0, len(n.Scores))
+		for name := range n.Scores {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		scores := make([]string, len(names))
+		for i, name := range names {
+			scores[i] = fmt.Sprintf("%s=%.3f", name, n.Scores[name])
+		}
+		fmt.Fprintf(tw, "%s\tyes\t%.3f\t%s\t\n", n.Name, n.Score, strings.Join(scores, " "))
+	}
+	tw.Flush()
+
+	if e.Selected == "" {
+		fmt.Fprintf(w, "\nScheduler %s would place the task on no worker\n", e.Scheduler)
+		return
+	}
+	fmt.Fprintf(w, "\nScheduler %s would place the task on %s\n", e.Scheduler, e.Selected)
+}
@@ -1,51 +1,37 @@
 package cmd
 
 import (
-	"flag"
-	"github.com/golang-collections/collections/queue"
-	"github.com/google/uuid"
-	"log"
-	"orchestra/task"
-	"orchestra/worker"
-	"time"
+	"fmt"
+	"os"
 )
 
-var (
-	Host string
-	Port int
-)
+const usage = `Usage: orchestra <command> [flags]
 
-func setupFlags() {
-	flag.StringVar(&Host, "host", "localhost", "Host on which orchestra runs")
-	flag.IntVar(&Port, "port", 7777, "port to run orchestra")
-}
+Commands:
+  worker    run a worker that executes tasks (default)
+  manager   run the manager that users submit tasks to
+  dry-run   ask the manager where it would place a task, and why
 
-func runTasks(w *worker.Worker) {
-	for {
-		if w.Queue.Len() != 0 {
-			result := w.RunTask()
-			if result.Error != nil {
-				log.Printf("Error running task: %s", result.Error)
-			}
-		} else {
-			log.Printf("No task found to be processed in the queue.")
-		}
-		log.Println("sleeping for 10 seconds.")
-		time.Sleep(10 * time.Second)
-	}
-}
+Run 'orchestra <command> -h' to see the flags of a command.
+`
 
 // Execute TODO: Check the goprocinfo library to update `stats.go` ioutil.ReadFile(path) code.
 func Execute() {
-	setupFlags()
-
-	w := worker.Worker{
-		Queue: *queue.New(),
-		Db:    make(map[uuid.UUID]*task.Task),
+	args := os.Args[1:]
+	command := "worker"
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		command, args = args[0], args[1:]
 	}
 
-	api := worker.API{Address: Host, Port: Port, Worker: &w}
-	go runTasks(&w)
-	go w.CollectStats()
-	api.Start()
+	switch command {
+	case "worker":
+		runWorker(args)
+	case "manager":
+		runManager(args)
+	case "dry-run":
+		runDryRun(args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
 }
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"flag"
+	"log"
+	"orchestra/manager"
+	"orchestra/scheduler"
+	"time"
+)
+
+func runManager(args []string) {
+	fs := flag.NewFlagSet("manager", flag.ExitOnError)
+	host := fs.String("host", "localhost", "Host on which the manager API listens")
+	port := fs.Int("port", 5555, "Port on which the manager API listens")
+	notReadyAfter := fs.Duration("not-ready-after", manager.DefaultNotReadyAfter, "How long a worker may miss heartbeats before it's marked NotReady")
+	goneAfter := fs.Duration("gone-after", manager.DefaultGoneAfter, "How long a worker may miss heartbeats before it's marked Gone")
+	reconcileInterval := fs.Duration("reconcile-interval", manager.DefaultReconcileInterval, "How often the desired state of tasks is compared with what the workers report")
+	schedulerName := fs.String("scheduler", "roundrobin", "How workers are picked for tasks that don't ask for a strategy: roundrobin, epvm, binpack or spread")
+	groupTimeout := fs.Duration("group-timeout", manager.DefaultGroupTimeout, "How long the members of a task group may wait to be placed together before they're failed")
+	initialBackoff := fs.Duration("initial-backoff", manager.DefaultInitialBackoff, "How long a task no worker can take waits before it's tried again; the wait doubles with every failure")
+	maxBackoff := fs.Duration("max-backoff", manager.DefaultMaxBackoff, "The longest a task no worker can take waits before it's tried again")
+	restartBackoff := fs.Duration("restart-backoff", manager.DefaultRestartBackoff, "How long a task that was restarted waits before it's restarted again; the wait doubles with every restart")
+	maxRestartBackoff := fs.Duration("max-restart-backoff", manager.DefaultMaxRestartBackoff, "The longest a task that keeps stopping waits between restarts")
+	dbPath := fs.String("db", "", "Path of the file the manager keeps its state in; the state is kept in memory when empty")
+	fs.Parse(args)
+
+	var store manager.Store = manager.NewMemoryStore()
+	if *dbPath != "" {
+		boltStore, err := manager.NewBoltStore(*dbPath)
+		if err != nil {
+			log.Fatalf("Error opening the manager store: %v", err)
+		}
+		store = boltStore
+	}
+	defer store.Close()
+
+	m, err := manager.New(store)
+	if err != nil {
+		log.Fatalf("Error starting the manager: %v", err)
+	}
+	m.NotReadyAfter = *notReadyAfter
+	m.GoneAfter = *goneAfter
+	m.GroupTimeout = *groupTimeout
+	m.Unschedulable.InitialBackoff = *initialBackoff
+	m.Unschedulable.MaxBackoff = *maxBackoff
+	m.Restarts.InitialBackoff = *restartBackoff
+	m.Restarts.MaxBackoff = *maxRestartBackoff
+	if m.Scheduler, err = scheduler.New(*schedulerName); err != nil {
+		log.Fatalf("Error starting the manager: %v", err)
+	}
+
+	api := manager.API{Address: *host, Port: *port, Manager: m}
+	go m.ProcessTasks()
+	go m.UpdateTasksForever()
+	go m.CheckWorkersForever(5 * time.Second)
+	go m.ReconcileForever(*reconcileInterval)
+	api.Start()
+}
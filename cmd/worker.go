@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"orchestra/node"
+	"orchestra/task"
+	"orchestra/worker"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+func runWorker(args []string) {
+	fs := flag.NewFlagSet("worker", flag.ExitOnError)
+	host := fs.String("host", "localhost", "Host on which the worker API listens")
+	port := fs.Int("port", 7777, "Port on which the worker API listens")
+	name := fs.String("name", defaultWorkerName(), "Name the worker registers with the manager under")
+	managerAddr := fs.String("manager", "localhost:5555", "Address (host:port) of the manager to register with")
+	heartbeat := fs.Duration("heartbeat", 10*time.Second, "Interval between heartbeats sent to the manager")
+	labels := labelsFlag{}
+	fs.Var(labels, "label", "Label describing the worker's node to the manager, as key=value; may be repeated")
+	reservedCPU := fs.Float64("reserved-cpu", 0, "Cores of the node kept for the system, which the manager doesn't allocate to tasks")
+	reservedMemory := fs.Int("reserved-memory", 0, "Memory of the node in bytes kept for the system, which the manager doesn't allocate to tasks")
+	reservedDisk := fs.Int("reserved-disk", 0, "Disk space of the node in bytes kept for the system, which the manager doesn't allocate to tasks")
+	cpuOvercommit := fs.Float64("cpu-overcommit", 1, "Ratio of the cores the manager may allocate to tasks to the unreserved cores of the node")
+	memoryOvercommit := fs.Float64("memory-overcommit", 1, "Ratio of the memory the manager may allocate to tasks to the unreserved memory of the node")
+	dbPath := fs.String("db", "", "Path of the file the worker keeps its task records in; they're kept in memory when empty")
+	processDir := fs.String("process-dir", task.DefaultProcessStateDir, "Directory the process runtime keeps the logs and state of its processes in")
+	wasmDir := fs.String("wasm-dir", task.DefaultWasmStateDir, "Directory the wasm runtime keeps downloaded modules and the logs and state of its instances in")
+	cgroupRoot := fs.String("cgroup-root", task.DefaultCgroupRoot, "cgroup v2 directory under which the process runtime creates a cgroup per process")
+	healthRestartBackoff := fs.Duration("health-restart-backoff", worker.DefaultHealthRestartBackoff, "How long a task restarted for being unhealthy waits before it's restarted again for it; the wait doubles with every restart")
+	maxHealthRestartBackoff := fs.Duration("max-health-restart-backoff", worker.DefaultMaxHealthRestartBackoff, "The longest a task that keeps being unhealthy waits between restarts")
+	fs.Parse(args)
+
+	if *reservedCPU < 0 || *reservedMemory < 0 || *reservedDisk < 0 {
+		log.Fatalf("Reserved resources can't be negative")
+	}
+	if *cpuOvercommit <= 0 || *memoryOvercommit <= 0 {
+		log.Fatalf("Overcommit ratios must be positive")
+	}
+
+	var store worker.Store = worker.NewMemoryStore()
+	if *dbPath != "" {
+		boltStore, err := worker.NewBoltStore(*dbPath)
+		if err != nil {
+			log.Fatalf("Error opening the worker store: %v", err)
+		}
+		store = boltStore
+	}
+	defer store.Close()
+
+	docker, err := task.NewDocker()
+	if err != nil {
+		log.Fatalf("Error setting up the container runtime: %v", err)
+	}
+
+	w := worker.New(*name, fmt.Sprintf("%s:%d", *host, *port), *managerAddr, store, docker)
+	w.Labels = labels
+	w.Reserved = node.Resources{CPU: *reservedCPU, Memory: *reservedMemory, Disk: *reservedDisk}
+	w.Overcommit = node.Overcommit{CPU: *cpuOvercommit, Memory: *memoryOvercommit}
+	w.HealthRestartBackoff = *healthRestartBackoff
+	w.MaxHealthRestartBackoff = *maxHealthRestartBackoff
+	w.Runtimes = map[string]task.Runtime{"docker": docker}
+	process, err := task.NewProcess(*processDir, *cgroupRoot)
+	if err != nil {
+		log.Printf("Process runtime unavailable, tasks asking for it will fail: %v", err)
+	} else {
+		w.Runtimes["process"] = process
+	}
+	wasm, err := task.NewWasm(*wasmDir)
+	if err != nil {
+		log.Printf("Wasm runtime unavailable, tasks asking for it will fail: %v", err)
+	} else {
+		w.Runtimes["wasm"] = wasm
+	}
+	if err := w.Reconcile(); err != nil {
+		log.Printf("Error reconciling tasks with their containers: %v", err)
+	}
+
+	api := worker.API{Address: *host, Port: *port, Worker: w}
+	go w.ProcessTasks()
+	go w.CollectStats()
+	go w.UpdateTasksForever(15 * time.Second)
+	go w.CheckHealthForever(time.Second)
+	go w.SendHeartbeats(*heartbeat)
+	api.Start()
+}
+
+// labelsFlag collects the key=value pairs given to a repeated flag.
+type labelsFlag map[string]string
+
+func (l labelsFlag) String() string {
+	pairs := make([]string, 0, len(l))
+	for k, v := range l {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (l labelsFlag) Set(value string) error {
+	k, v, ok := strings.Cut(value, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("label %q is not of the form key=value", value)
+	}
+	l[k] = v
+	return nil
+}
+
+// defaultWorkerName names the worker after the host it runs on.
+func defaultWorkerName() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "worker"
+	}
+	return hostname
+}
@@ -0,0 +1,225 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"orchestra/task"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultGroupTimeout is how long the members of a task group may wait to be placed before they're failed.
+const DefaultGroupTimeout = 5 * time.Minute
+
+// taskGroup gathers the members of a task group taken off the Pending queue until they can all be placed.
+type taskGroup struct {
+	size    int              // size is the number of members the group needs before it's placed.
+	members []task.TaskEvent // members are the events of the members gathered so far.
+	since   time.Time        // since is when the first member was gathered; the group times out from then.
+}
+
+// placement is a member of a task group assigned to a worker, waiting to be sent there.
+type placement struct {
+	te   task.TaskEvent
+	name string // name is the name of the worker.
+	api  string // api is the address of the worker.
+}
+
+// gather adds a member of a task group to its group, and places the group once all its members are there.
+// It releases m.mu, which the caller must hold.
+func (m *Manager) gather(te task.TaskEvent) {
+	name := te.Task.Group
+	g, ok := m.Groups[name]
+	if !ok {
+		g = &taskGroup{size: te.Task.GroupSize, since: time.Now().UTC()}
+		m.Groups[name] = g
+	}
+	for _, member := range g.members {
+		if member.Task.ID == te.Task.ID {
+			m.mu.Unlock()
+			return
+		}
+	}
+	g.members = append(g.members, te)
+	log.Printf("Gathered task %v, member %d of %d of group %s", te.Task.ID, len(g.members), g.size, name)
+
+	if len(g.members) < g.size {
+		m.mu.Unlock()
+		return
+	}
+	since := g.since
+	placed := m.placeGroup(name)
+	m.mu.Unlock()
+
+	m.sendGroup(name, since, placed)
+}
+
+// PlaceGroups places the task groups whose members are all gathered but didn't fit when they were,
+// and fails the members of the groups that waited longer than GroupTimeout.
+func (m *Manager) PlaceGroups() {
+	m.mu.Lock()
+	now := time.Now().UTC()
+	var ready []string
+	for name, g := range m.Groups {
+		switch {
+		case now.Sub(g.since) > m.GroupTimeout:
+			m.failGroup(name, fmt.Sprintf("group %s was not placed within %v", name, m.GroupTimeout))
+		case len(g.members) >= g.size:
+			ready = append(ready, name)
+		}
+	}
+
+	placements := make(map[string][]placement)
+	since := make(map[string]time.Time)
+	for _, name := range ready {
+		since[name] = m.Groups[name].since
+		if placed := m.placeGroup(name); placed != nil {
+			placements[name] = placed
+		}
+	}
+	m.mu.Unlock()
+
+	for name, placed := range placements {
+		m.sendGroup(name, since[name], placed)
+	}
+}
+
+// placeGroup assigns every member of a gathered group to a worker, each assignment reserving capacity for
+// the next members, and removes the group from Groups. If a member can't be placed, no member is and the
+// group keeps waiting. It returns the placements to send, or nil. The caller must hold m.mu.
+func (m *Manager) placeGroup(name string) []placement {
+	g := m.Groups[name]
+	for _, te := range g.members {
+		if t, ok := m.TasksDb[te.Task.ID]; ok && t.DesiredState == task.Completed {
+			m.stopGroup(name, te.Task.ID)
+			return nil
+		}
+	}
+
+	var placed []placement
+	for _, te := range g.members {
+		n, err := m.SelectWorker(te.Task)
+		if err != nil {
+			log.Printf("Unable to place task %v of group %s, releasing the %d members placed so far: %v", te.Task.ID, name, len(placed), err)
+			m.release(placed)
+			reason := fmt.Sprintf("group %s doesn't fit, task %v: %s", name, te.Task.ID, m.unschedulableReason(te.Task))
+			for _, member := range g.members {
+				if t, ok := m.TasksDb[member.Task.ID]; ok {
+					t.PendingReason = reason
+					m.saveTask(t)
+				}
+			}
+			return nil
+		}
+
+		t := te.Task
+		t.State = task.Scheduled
+		t.PendingReason = ""
+		te.Task = t
+		m.assign(t.ID, n.Name)
+		m.TasksDb[t.ID] = &t
+		m.saveTask(&t)
+		placed = append(placed, placement{te: te, name: n.Name, api: n.Api})
+	}
+
+	delete(m.Groups, name)
+	for _, p := range placed {
+		p.te.Reason = fmt.Sprintf("placed on worker %s with the %d members of group %s", p.name, len(placed), name)
+		m.recordEvent(p.te)
+	}
+	log.Printf("Placed the %d members of group %s", len(placed), name)
+	return placed
+}
+
+// sendGroup sends the placed members of a group, gathered since the given time, to their workers, so that
+// the group never runs partially:
+//
+//  1. If a worker refuses its member, the member would be refused again, so the whole group is failed.
+//  2. If a worker can't be reached, the group waits to be placed again, still timing out from since.
+//
+// Either way the members already sent are stopped, including the one whose worker may have started it
+// without answering.
+func (m *Manager) sendGroup(name string, since time.Time, placed []placement) {
+	for i, p := range placed {
+		err := m.startTask(p.api, p.te)
+		if err == nil {
+			continue
+		}
+
+		sent := placed[:i+1]
+		m.mu.Lock()
+		m.release(placed)
+		g := &taskGroup{size: len(placed), since: since}
+		for _, q := range placed {
+			q.te.Task.State = task.Pending
+			g.members = append(g.members, q.te)
+		}
+		m.Groups[name] = g
+		if errors.Is(err, errRejected) {
+			log.Printf("Worker %v rejected task %v of group %s, failing the group: %v", p.name, p.te.Task.ID, name, err)
+			m.failGroup(name, fmt.Sprintf("task %v was rejected by worker %s: %v", p.te.Task.ID, p.name, err))
+			sent = placed[:i]
+		} else {
+			log.Printf("Error sending task %v of group %s to %v, taking the group back: %v", p.te.Task.ID, name, p.name, err)
+		}
+		m.mu.Unlock()
+
+		for _, q := range sent {
+			m.stopTask(q.api, q.te.Task.ID)
+		}
+		return
+	}
+}
+
+// release takes the placed members of a group off their workers, freeing the capacity they reserved.
+// The caller must hold m.mu.
+func (m *Manager) release(placed []placement) {
+	for _, p := range placed {
+		m.unassign(p.te.Task.ID)
+		if t, ok := m.TasksDb[p.te.Task.ID]; ok {
+			t.State = task.Pending
+			m.saveTask(t)
+		}
+	}
+}
+
+// failGroup marks every gathered member of a group Failed, recording why, and forgets the group.
+// The caller must hold m.mu.
+func (m *Manager) failGroup(name, reason string) {
+	log.Printf("Failing the members of group %s: %s", name, reason)
+	m.finishGroup(name, task.Failed, reason)
+}
+
+// stopGroup marks every gathered member of a group Completed because one of them was stopped before
+// the group was placed, and forgets the group. The caller must hold m.mu.
+func (m *Manager) stopGroup(name string, stopped uuid.UUID) {
+	log.Printf("Task %v of group %s was stopped before the group was placed, stopping the whole group", stopped, name)
+	m.finishGroup(name, task.Completed, fmt.Sprintf("task %v of group %s was stopped before the group was placed", stopped, name))
+}
+
+// finishGroup ends the gathered members of a group in the given state. The caller must hold m.mu.
+func (m *Manager) finishGroup(name string, state task.State, reason string) {
+	now := time.Now().UTC()
+	for _, te := range m.Groups[name].members {
+		t, ok := m.TasksDb[te.Task.ID]
+		if !ok {
+			continue
+		}
+		t.State = state
+		if state == task.Completed {
+			t.DesiredState = task.Completed
+		}
+		t.FinishTime = now
+		m.saveTask(t)
+		m.recordEvent(task.TaskEvent{
+			ID:        uuid.New(),
+			State:     state,
+			TimeStamp: now,
+			Task:      *t,
+			Reason:    reason,
+		})
+	}
+	delete(m.Groups, name)
+}
@@ -0,0 +1,168 @@
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"orchestra/task"
+	"orchestra/task/fake"
+	"orchestra/worker"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// registerPinned registers a worker labelled with its name, for the members of a group to be pinned to it.
+func registerPinned(tb testing.TB, m *Manager, name, api string) {
+	tb.Helper()
+	n := nodeFor(name, api)
+	n.Labels = map[string]string{"name": name}
+	if err := m.RegisterWorker(n); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// addMembers submits a group with a member pinned to each of the given workers, and takes the members off
+// the Pending queue, which places the group once they're all gathered.
+func addMembers(tb testing.TB, m *Manager, workers ...string) []uuid.UUID {
+	tb.Helper()
+	var ids []uuid.UUID
+	for _, w := range workers {
+		t := task.Task{
+			ID:          uuid.New(),
+			Image:       "nginx:1",
+			Group:       "g",
+			GroupSize:   len(workers),
+			Constraints: []task.Constraint{{Key: "name", Operator: task.Equals, Values: []string{w}}},
+		}
+		if err := m.AddTask(task.TaskEvent{ID: uuid.New(), State: task.Scheduled, TimeStamp: time.Now(), Task: t}); err != nil {
+			tb.Fatal(err)
+		}
+		ids = append(ids, t.ID)
+	}
+	for range workers {
+		m.SendWork()
+	}
+	return ids
+}
+
+func TestSendGroupFailsRejectedGroup(t *testing.T) {
+	m := newTestManager(t)
+	w1, rt, api := newTestWorker(t, "w1")
+	registerPinned(t, m, "w1", api)
+	rejecting := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(rw).Encode(worker.ErrorResponse{Message: "unknown runtime", HttpStatusCode: http.StatusBadRequest})
+	}))
+	defer rejecting.Close()
+	registerPinned(t, m, "w2", strings.TrimPrefix(rejecting.URL, "http://"))
+
+	ids := addMembers(t, m, "w1", "w2")
+
+	if _, waiting := m.Groups["g"]; waiting {
+		t.Error("rejected group is waiting to be placed again")
+	}
+	for _, id := range ids {
+		got, events, _ := m.GetTask(id)
+		if got.State != task.Failed {
+			t.Errorf("member %v is %v, want %v", id, got.State, task.Failed)
+		}
+		if last := events[len(events)-1]; !strings.Contains(last.Reason, "rejected") {
+			t.Errorf("last event of member %v has reason %q, want the rejection", id, last.Reason)
+		}
+		if w, ok := m.TaskWorkerMap[id]; ok {
+			t.Errorf("member %v is still assigned to %s", id, w)
+		}
+	}
+
+	// the member w1 took is stopped there.
+	for i := 0; i < 2; i++ {
+		if result := w1.RunTask(); result.Error != nil {
+			t.Fatalf("w1 RunTask() error = %v", result.Error)
+		}
+	}
+	if got, _ := w1.GetTask(ids[0]); got.State != task.Completed || len(rt.Containers()) != 0 {
+		t.Errorf("member is %v on w1 with %d containers, want it stopped", got.State, len(rt.Containers()))
+	}
+}
+
+// TestSendGroupRequeuesUnreachableGroup checks that a group whose member didn't get through waits to be placed
+// again from when it was first gathered, and that the member is stopped in case its worker took it anyway.
+func TestSendGroupRequeuesUnreachableGroup(t *testing.T) {
+	m := newTestManager(t)
+	w1, _, api := newTestWorker(t, "w1")
+	registerPinned(t, m, "w1", api)
+
+	// w2 takes its member, but its answer is lost.
+	w2 := worker.New("w2", "", "", worker.NewMemoryStore(), fake.NewRuntime())
+	a := &worker.API{Worker: w2}
+	r := chi.NewRouter()
+	r.Post("/tasks", func(rw http.ResponseWriter, req *http.Request) {
+		a.StartTaskHandler(httptest.NewRecorder(), req)
+		panic(http.ErrAbortHandler)
+	})
+	r.Delete("/tasks/{taskID}", a.StopTaskHandler)
+	flaky := httptest.NewServer(r)
+	defer flaky.Close()
+	registerPinned(t, m, "w2", strings.TrimPrefix(flaky.URL, "http://"))
+
+	since := time.Now().UTC()
+	ids := addMembers(t, m, "w1", "w2")
+
+	g, waiting := m.Groups["g"]
+	if !waiting {
+		t.Fatal("group isn't waiting to be placed again")
+	}
+	if g.since.Before(since) || time.Since(g.since) > time.Minute || len(g.members) != 2 {
+		t.Errorf("group gathered since %v with %d members, want it gathered since it was first", g.since, len(g.members))
+	}
+	for _, id := range ids {
+		if got, _, _ := m.GetTask(id); got.State != task.Pending {
+			t.Errorf("member %v is %v, want %v", id, got.State, task.Pending)
+		}
+	}
+
+	// both members are stopped, including the one w2 took without answering.
+	for _, w := range []*worker.Worker{w1, w2} {
+		for i := 0; i < 2; i++ {
+			if result := w.RunTask(); result.Error != nil {
+				t.Fatalf("%s RunTask() error = %v", w.Name, result.Error)
+			}
+		}
+	}
+	if got, _ := w1.GetTask(ids[0]); got.State != task.Completed {
+		t.Errorf("member is %v on w1, want it stopped", got.State)
+	}
+	if got, _ := w2.GetTask(ids[1]); got.State != task.Completed {
+		t.Errorf("member is %v on w2, want it stopped", got.State)
+	}
+}
+
+// TestGroupTimesOutFromFirstGathering checks that sending a group again doesn't push its timeout back.
+func TestGroupTimesOutFromFirstGathering(t *testing.T) {
+	m := newTestManager(t)
+	m.GroupTimeout = time.Minute
+	_, _, api := newTestWorker(t, "w1")
+	registerPinned(t, m, "w1", api)
+	registerPinned(t, m, "w2", "127.0.0.1:1")
+
+	ids := addMembers(t, m, "w1", "w2")
+	g, waiting := m.Groups["g"]
+	if !waiting {
+		t.Fatal("group isn't waiting to be placed again")
+	}
+	g.since = g.since.Add(-2 * time.Minute)
+
+	m.PlaceGroups()
+	if _, waiting := m.Groups["g"]; waiting {
+		t.Error("group still waiting past its timeout")
+	}
+	for _, id := range ids {
+		if got, _, _ := m.GetTask(id); got.State != task.Failed {
+			t.Errorf("member %v is %v after the group timed out, want %v", id, got.State, task.Failed)
+		}
+	}
+}
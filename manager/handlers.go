@@ -0,0 +1,202 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"orchestra/node"
+	"orchestra/task"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// TaskResponse is what the manager returns for a single task: its latest known
+// state together with the events that were submitted for it.
+type TaskResponse struct {
+	Task   task.Task        `json:"task"`
+	Events []task.TaskEvent `json:"events"`
+}
+
+func (a *API) StartTaskHandler(w http.ResponseWriter, r *http.Request) {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	var te task.TaskEvent
+	if err := d.Decode(&te); err != nil {
+		errMsg := fmt.Sprintf("Failed to decode task event: %v", err)
+		a.APIError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	if te.ID == uuid.Nil {
+		te.ID = uuid.New()
+	}
+	if te.Task.ID == uuid.Nil {
+		te.Task.ID = uuid.New()
+	}
+	if te.TimeStamp.IsZero() {
+		te.TimeStamp = time.Now().UTC()
+	}
+	te.State = task.Scheduled
+	te.Task.State = task.Pending
+
+	if err := a.Manager.AddTask(te); err != nil {
+		status := http.StatusConflict
+		if errors.Is(err, ErrInvalidTask) {
+			status = http.StatusBadRequest
+		}
+		a.APIError(w, status, err.Error())
+		return
+	}
+	log.Printf("Added task %v\n", te.Task.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(te.Task)
+}
+
+// DryRunHandler explains where the task in the request would be placed, without placing it.
+func (a *API) DryRunHandler(w http.ResponseWriter, r *http.Request) {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	var te task.TaskEvent
+	if err := d.Decode(&te); err != nil {
+		errMsg := fmt.Sprintf("Failed to decode task event: %v", err)
+		a.APIError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	e, err := a.Manager.DryRun(te.Task)
+	if err != nil {
+		a.APIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(e)
+}
+
+func (a *API) GetTasksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(a.Manager.GetTasks())
+}
+
+func (a *API) GetTaskHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := a.taskID(w, r)
+	if !ok {
+		return
+	}
+
+	t, events, found := a.Manager.GetTask(id)
+	if !found {
+		a.APIError(w, http.StatusNotFound, fmt.Sprintf("Task not found: %v", id))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TaskResponse{Task: t, Events: events})
+}
+
+func (a *API) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := a.taskID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := a.Manager.StopTask(id); err != nil {
+		a.APIError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	log.Printf("Added task %v to be stopped.\n", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// taskID parses the {taskID} URL parameter, answering the request with an error when it isn't a valid UUID.
+func (a *API) taskID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	tID := chi.URLParam(r, "taskID")
+	id, err := uuid.Parse(tID)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to parse the task ID %q: %v", tID, err)
+		a.APIError(w, http.StatusBadRequest, errMsg)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func (a *API) RegisterNodeHandler(w http.ResponseWriter, r *http.Request) {
+	var n node.Node
+	if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+		errMsg := fmt.Sprintf("Failed to decode node: %v", err)
+		a.APIError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	if err := a.Manager.RegisterWorker(n); err != nil {
+		a.APIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(n)
+}
+
+func (a *API) HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "nodeName")
+
+	var n node.Node
+	if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+		errMsg := fmt.Sprintf("Failed to decode node: %v", err)
+		a.APIError(w, http.StatusBadRequest, errMsg)
+		return
+	}
+
+	if err := a.Manager.Heartbeat(name, n); err != nil {
+		a.APIError(w, http.StatusNotFound, fmt.Sprintf("%v: %s", err, name))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// SetTaintsHandler replaces the taints of a worker with the list in the request body.
+func (a *API) SetTaintsHandler(w http.ResponseWriter, r *http.Request) {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	var taints []node.Taint
+	if err := d.Decode(&taints); err != nil {
+		a.APIError(w, http.StatusBadRequest, fmt.Sprintf("Failed to decode taints: %v", err))
+		return
+	}
+	for _, t := range taints {
+		if err := t.Validate(); err != nil {
+			a.APIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	n, err := a.Manager.SetTaints(chi.URLParam(r, "nodeName"), taints)
+	if err != nil {
+		a.APIError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(n)
+}
+
+func (a *API) GetNodesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(a.Manager.GetNodes())
+}
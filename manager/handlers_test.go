@@ -0,0 +1,304 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"orchestra/node"
+	"orchestra/scheduler"
+	"orchestra/task"
+	"orchestra/task/fake"
+	"orchestra/worker"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// newTestAPI serves the API of a manager backed by a MemoryStore.
+func newTestAPI(tb testing.TB) (*httptest.Server, *Manager) {
+	tb.Helper()
+	m, err := New(NewMemoryStore())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	a := &API{Manager: m}
+	a.initRouter()
+	srv := httptest.NewServer(a.Router)
+	tb.Cleanup(srv.Close)
+	return srv, m
+}
+
+// newTestWorker serves the task API of a worker backed by a fake runtime, and returns the worker with
+// the "host:port" address the manager reaches it at.
+func newTestWorker(tb testing.TB, name string) (*worker.Worker, *fake.Runtime, string) {
+	tb.Helper()
+	rt := fake.NewRuntime()
+	w := worker.New(name, "", "", worker.NewMemoryStore(), rt)
+	a := &worker.API{Worker: w}
+
+	r := chi.NewRouter()
+	r.Post("/tasks", a.StartTaskHandler)
+	r.Get("/tasks", a.GetTaskHandler)
+	r.Delete("/tasks/{taskID}", a.StopTaskHandler)
+	srv := httptest.NewServer(r)
+	tb.Cleanup(srv.Close)
+	return w, rt, strings.TrimPrefix(srv.URL, "http://")
+}
+
+// send makes a request with the JSON encoding of body, if it isn't nil, and decodes the response into out,
+// if it isn't nil. It returns the status of the response.
+func send(tb testing.TB, method, url string, body, out any) int {
+	tb.Helper()
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(data))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			tb.Fatalf("%s %s: unable to decode the response: %v", method, url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func registerWorker(tb testing.TB, url, name, api string) {
+	tb.Helper()
+	if status := send(tb, http.MethodPost, url+"/nodes", nodeFor(name, api), nil); status != http.StatusCreated {
+		tb.Fatalf("POST /nodes status = %d, want %d", status, http.StatusCreated)
+	}
+}
+
+func submit(tb testing.TB, url string, t task.Task) task.Task {
+	tb.Helper()
+	var created task.Task
+	if status := send(tb, http.MethodPost, url+"/tasks", task.TaskEvent{Task: t}, &created); status != http.StatusCreated {
+		tb.Fatalf("POST /tasks status = %d, want %d", status, http.StatusCreated)
+	}
+	return created
+}
+
+func TestStartTaskHandler(t *testing.T) {
+	srv, m := newTestAPI(t)
+
+	created := submit(t, srv.URL, task.Task{Name: "web", Image: "nginx:1"})
+	if created.ID == uuid.Nil {
+		t.Fatal("POST /tasks returned a task without an ID")
+	}
+	if created.State != task.Pending {
+		t.Errorf("task state = %v, want %v", created.State, task.Pending)
+	}
+	if m.Pending.Len() != 1 {
+		t.Errorf("Pending holds %d events, want 1", m.Pending.Len())
+	}
+
+	var resp TaskResponse
+	if status := send(t, http.MethodGet, srv.URL+"/tasks/"+created.ID.String(), nil, &resp); status != http.StatusOK {
+		t.Fatalf("GET /tasks/{id} status = %d, want %d", status, http.StatusOK)
+	}
+	if resp.Task.ID != created.ID || resp.Task.DesiredState != task.Running {
+		t.Errorf("GET /tasks/{id} = %+v, want task %v desired running", resp.Task, created.ID)
+	}
+
+	var tasks []task.Task
+	if status := send(t, http.MethodGet, srv.URL+"/tasks", nil, &tasks); status != http.StatusOK || len(tasks) != 1 {
+		t.Errorf("GET /tasks = %d with %d tasks, want %d with 1", status, len(tasks), http.StatusOK)
+	}
+}
+
+func TestStartTaskHandlerRejectsInvalidTasks(t *testing.T) {
+	srv, m := newTestAPI(t)
+
+	tests := []struct {
+		name string
+		task task.Task
+	}{
+		{"unknown strategy", task.Task{Strategy: "fastest"}},
+		{"memory below the Docker minimum", task.Task{Memory: 1 << 20}},
+		{"invalid port", task.Task{PortBindings: map[string]string{"80": "http"}}},
+		{"group without size", task.Task{Group: "db"}},
+	}
+	for _, tt := range tests {
+		if status := send(t, http.MethodPost, srv.URL+"/tasks", task.TaskEvent{Task: tt.task}, nil); status != http.StatusBadRequest {
+			t.Errorf("POST /tasks with %s status = %d, want %d", tt.name, status, http.StatusBadRequest)
+		}
+	}
+
+	resp, err := http.Post(srv.URL+"/tasks", "application/json", strings.NewReader(`{"Task": {}, "Unknown": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST /tasks with an unknown field status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	if m.Pending.Len() != 0 {
+		t.Errorf("Pending holds %d events from invalid requests", m.Pending.Len())
+	}
+}
+
+func TestStartTaskHandlerRejectsDuplicates(t *testing.T) {
+	srv, _ := newTestAPI(t)
+	created := submit(t, srv.URL, task.Task{Image: "nginx:1"})
+
+	if status := send(t, http.MethodPost, srv.URL+"/tasks", task.TaskEvent{Task: created}, nil); status != http.StatusConflict {
+		t.Errorf("POST /tasks with an existing task status = %d, want %d", status, http.StatusConflict)
+	}
+}
+
+func TestTaskHandlersErrors(t *testing.T) {
+	srv, _ := newTestAPI(t)
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/tasks/not-a-uuid", http.StatusBadRequest},
+		{http.MethodGet, "/tasks/" + uuid.NewString(), http.StatusNotFound},
+		{http.MethodDelete, "/tasks/not-a-uuid", http.StatusBadRequest},
+		{http.MethodDelete, "/tasks/" + uuid.NewString(), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if status := send(t, tt.method, srv.URL+tt.path, nil, nil); status != tt.want {
+			t.Errorf("%s %s status = %d, want %d", tt.method, tt.path, status, tt.want)
+		}
+	}
+}
+
+func TestNodeHandlers(t *testing.T) {
+	srv, _ := newTestAPI(t)
+
+	if status := send(t, http.MethodPost, srv.URL+"/nodes", node.Node{Name: "nameless"}, nil); status != http.StatusBadRequest {
+		t.Errorf("POST /nodes without an API address status = %d, want %d", status, http.StatusBadRequest)
+	}
+	if status := send(t, http.MethodPost, srv.URL+"/nodes/w1/heartbeat", node.Node{Name: "w1"}, nil); status != http.StatusNotFound {
+		t.Errorf("heartbeat of an unregistered worker status = %d, want %d", status, http.StatusNotFound)
+	}
+
+	registerWorker(t, srv.URL, "w1", "127.0.0.1:5556")
+	heartbeat := node.Node{Name: "w1", Cores: 4, Memory: 8 << 30, Labels: map[string]string{"disk": "ssd"}}
+	if status := send(t, http.MethodPost, srv.URL+"/nodes/w1/heartbeat", heartbeat, nil); status != http.StatusOK {
+		t.Errorf("heartbeat status = %d, want %d", status, http.StatusOK)
+	}
+
+	var nodes []node.Node
+	if status := send(t, http.MethodGet, srv.URL+"/nodes", nil, &nodes); status != http.StatusOK {
+		t.Fatalf("GET /nodes status = %d, want %d", status, http.StatusOK)
+	}
+	if len(nodes) != 1 || nodes[0].Status != node.Ready || nodes[0].Labels["disk"] != "ssd" {
+		t.Errorf("GET /nodes = %+v, want the ready w1 with its labels", nodes)
+	}
+
+	taints := []node.Taint{{Key: "gpu", Value: "true", Effect: node.NoSchedule}}
+	if status := send(t, http.MethodPut, srv.URL+"/nodes/w1/taints", taints, nil); status != http.StatusOK {
+		t.Errorf("PUT /nodes/w1/taints status = %d, want %d", status, http.StatusOK)
+	}
+	if status := send(t, http.MethodPut, srv.URL+"/nodes/w2/taints", taints, nil); status != http.StatusNotFound {
+		t.Errorf("PUT /nodes/w2/taints status = %d, want %d", status, http.StatusNotFound)
+	}
+}
+
+func TestDryRunHandler(t *testing.T) {
+	srv, m := newTestAPI(t)
+	registerWorker(t, srv.URL, "w1", "127.0.0.1:5556")
+	registerWorker(t, srv.URL, "w2", "127.0.0.1:5557")
+
+	var e scheduler.Explanation
+	te := task.TaskEvent{Task: task.Task{Image: "nginx:1", Memory: 16 << 30}}
+	if status := send(t, http.MethodPost, srv.URL+"/tasks/dry-run", te, &e); status != http.StatusOK {
+		t.Fatalf("POST /tasks/dry-run status = %d, want %d", status, http.StatusOK)
+	}
+	if e.Selected != "" || e.Summary() != "0/2 nodes available: 2 insufficient memory" {
+		t.Errorf("dry-run selected %q with summary %q, want no node for lack of memory", e.Selected, e.Summary())
+	}
+
+	if status := send(t, http.MethodPost, srv.URL+"/tasks/dry-run", task.TaskEvent{Task: task.Task{Strategy: "fastest"}}, nil); status != http.StatusBadRequest {
+		t.Errorf("POST /tasks/dry-run with an unknown strategy status = %d, want %d", status, http.StatusBadRequest)
+	}
+	if m.Pending.Len() != 0 || len(m.GetTasks()) != 0 {
+		t.Error("dry-run placed or recorded a task")
+	}
+}
+
+// TestTaskLifecycle runs a task through a manager and a worker backed by a fake runtime, from its submission
+// to its stop.
+func TestTaskLifecycle(t *testing.T) {
+	srv, m := newTestAPI(t)
+	w, rt, api := newTestWorker(t, "w1")
+	registerWorker(t, srv.URL, "w1", api)
+
+	created := submit(t, srv.URL, task.Task{Name: "web", Image: "nginx:1", Memory: 64 << 20})
+	m.SendWork()
+	if result := w.RunTask(); result.Error != nil {
+		t.Fatalf("worker RunTask() error = %v", result.Error)
+	}
+	m.UpdateTasks()
+
+	var resp TaskResponse
+	send(t, http.MethodGet, srv.URL+"/tasks/"+created.ID.String(), nil, &resp)
+	if resp.Task.State != task.Running || resp.Task.Runtime.ContainerId == "" {
+		t.Fatalf("task = %v in container %q, want it running", resp.Task.State, resp.Task.Runtime.ContainerId)
+	}
+	if len(resp.Events) != 1 || resp.Events[0].State != task.Scheduled {
+		t.Errorf("task events = %+v, want the scheduling event", resp.Events)
+	}
+	var nodes []node.Node
+	send(t, http.MethodGet, srv.URL+"/nodes", nil, &nodes)
+	if len(nodes) != 1 || nodes[0].MemoryAllocated != 64<<20 {
+		t.Errorf("GET /nodes = %+v, want w1 with the task's memory allocated", nodes)
+	}
+
+	if status := send(t, http.MethodDelete, srv.URL+"/tasks/"+created.ID.String(), nil, nil); status != http.StatusNoContent {
+		t.Fatalf("DELETE /tasks/{id} status = %d, want %d", status, http.StatusNoContent)
+	}
+	m.SendWork()
+	if result := w.RunTask(); result.Error != nil {
+		t.Fatalf("worker RunTask() error = %v", result.Error)
+	}
+	m.UpdateTasks()
+
+	send(t, http.MethodGet, srv.URL+"/tasks/"+created.ID.String(), nil, &resp)
+	if resp.Task.State != task.Completed || resp.Task.DesiredState != task.Completed {
+		t.Errorf("task = %v desired %v, want both completed", resp.Task.State, resp.Task.DesiredState)
+	}
+	if containers := rt.Containers(); len(containers) != 0 {
+		t.Errorf("runtime has %d containers after the task was stopped", len(containers))
+	}
+}
+
+// TestTaskLifecycleWithPullFailure checks that a task whose image can't be pulled is reported failed.
+func TestTaskLifecycleWithPullFailure(t *testing.T) {
+	srv, m := newTestAPI(t)
+	w, rt, api := newTestWorker(t, "w1")
+	registerWorker(t, srv.URL, "w1", api)
+	rt.FailPull("nginx:404", errors.New("manifest unknown"))
+
+	created := submit(t, srv.URL, task.Task{Image: "nginx:404"})
+	m.SendWork()
+	if result := w.RunTask(); result.Error == nil {
+		t.Fatal("worker RunTask() error = nil for an image that can't be pulled")
+	}
+	m.UpdateTasks()
+
+	if got, _, _ := m.GetTask(created.ID); got.State != task.Failed {
+		t.Errorf("task state = %v, want %v", got.State, task.Failed)
+	}
+}
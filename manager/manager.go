@@ -1,10 +1,19 @@
 package manager
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"net/http"
+	"orchestra/node"
+	"orchestra/scheduler"
 	"orchestra/task"
+	"orchestra/worker"
+	"sync"
+	"time"
 
-	"github.com/golang-collections/collections/queue"
 	"github.com/google/uuid"
 )
 
@@ -15,23 +24,704 @@ import (
 
 // Manager is responsible for managing tasks and workers within the system.
 type Manager struct {
-	Pending       queue.Queue                 // Pending is a queue that holds tasks waiting to be processed.
-	EventsDb      map[string][]task.TaskEvent // EventsDb maps task names to slices of TaskEvent, representing the history of events associated with each task.
-	TasksDb       map[string][]task.Task      // TasksDb maps task names to lists of Task objects, categorizing tasks within the system.
-	Workers       []string                    // Workers is a list of worker identifiers (name) assigned to manage tasks within the system.
-	WorkerTaskMap map[string][]uuid.UUID      // WorkerTaskMap maps worker identifiers to lists of UUIDs representing the tasks they are responsible for.
-	TaskWorkerMap map[uuid.UUID]string        // TaskWorkerMap maps task UUIDs to worker identifiers, indicating which worker is responsible for each task.
+	Pending       PendingQueue                   // Pending is a queue that holds tasks waiting to be processed, highest priority first.
+	Unschedulable UnschedulableQueue             // Unschedulable holds the tasks no worker could take until they're tried again.
+	Restarts      RestartBackoff                 // Restarts spaces out the restarts of the tasks that keep stopping.
+	EventsDb      map[uuid.UUID][]task.TaskEvent // EventsDb maps task IDs to slices of TaskEvent, representing the history of events associated with each task.
+	TasksDb       map[uuid.UUID]*task.Task       // TasksDb maps task IDs to the manager's latest view of each task.
+	Workers       map[string]*node.Node          // Workers maps the names of the workers that registered with the manager to their node and health.
+	WorkerTaskMap map[string][]uuid.UUID         // WorkerTaskMap maps worker identifiers to lists of UUIDs representing the tasks they are responsible for.
+	TaskWorkerMap map[uuid.UUID]string           // TaskWorkerMap maps task UUIDs to worker identifiers, indicating which worker is responsible for each task.
+	Scheduler     scheduler.Scheduler            // Scheduler picks the worker each task is placed on, unless the task asks for another Strategy.
+	Schedulers    map[string]scheduler.Scheduler // Schedulers holds the schedulers created for the tasks that asked for another Strategy, by name.
+	NotReadyAfter time.Duration                  // NotReadyAfter is how long a worker may go without a heartbeat before it's marked NotReady.
+	GoneAfter     time.Duration                  // GoneAfter is how long a worker may go without a heartbeat before it's marked Gone.
+	Groups        map[string]*taskGroup          // Groups holds the members of the task groups taken off the Pending queue until the whole group can be placed.
+	GroupTimeout  time.Duration                  // GroupTimeout is how long the members of a group may wait to be placed before they're failed.
+	Store         Store                          // Store persists the manager's state so that it survives a restart.
+
+	mu     sync.Mutex    // mu guards the fields above, which are shared by the API handlers and the processing loops.
+	notify chan struct{} // notify wakes ProcessTasks up when work is added to the Pending queue.
+}
+
+// New creates a Manager backed by the given store and reloads whatever state the store holds.
+// Workers join the cluster by registering with it.
+func New(store Store) (*Manager, error) {
+	m := &Manager{
+		EventsDb:      make(map[uuid.UUID][]task.TaskEvent),
+		TasksDb:       make(map[uuid.UUID]*task.Task),
+		Workers:       make(map[string]*node.Node),
+		WorkerTaskMap: make(map[string][]uuid.UUID),
+		TaskWorkerMap: make(map[uuid.UUID]string),
+		NotReadyAfter: DefaultNotReadyAfter,
+		GoneAfter:     DefaultGoneAfter,
+		Scheduler:     &scheduler.RoundRobin{},
+		Schedulers:    make(map[string]scheduler.Scheduler),
+		Groups:        make(map[string]*taskGroup),
+		GroupTimeout:  DefaultGroupTimeout,
+		Unschedulable: UnschedulableQueue{InitialBackoff: DefaultInitialBackoff, MaxBackoff: DefaultMaxBackoff},
+		Restarts:      RestartBackoff{InitialBackoff: DefaultRestartBackoff, MaxBackoff: DefaultMaxRestartBackoff},
+		Store:         store,
+		notify:        make(chan struct{}, 1),
+	}
+
+	if err := m.restore(); err != nil {
+		return nil, fmt.Errorf("unable to restore the manager's state: %w", err)
+	}
+	return m, nil
+}
+
+// restore loads the tasks, events, assignments and workers kept in the store. Restored workers
+// are NotReady until they send a heartbeat, and tasks that were waiting to be placed go back on
+// the Pending queue.
+func (m *Manager) restore() error {
+	tasks, err := m.Store.ListTasks()
+	if err != nil {
+		return err
+	}
+	for i := range tasks {
+		m.TasksDb[tasks[i].ID] = &tasks[i]
+	}
+
+	if m.EventsDb, err = m.Store.ListEvents(); err != nil {
+		return err
+	}
+
+	assignments, err := m.Store.ListAssignments()
+	if err != nil {
+		return err
+	}
+	for id, w := range assignments {
+		m.TaskWorkerMap[id] = w
+		m.WorkerTaskMap[w] = append(m.WorkerTaskMap[w], id)
+	}
+
+	nodes, err := m.Store.ListNodes()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for i := range nodes {
+		n := &nodes[i]
+		if n.Status != node.Gone {
+			// give the worker a chance to reach the restarted manager before it's declared gone.
+			n.Status = node.NotReady
+			n.LastHeartbeat = now
+		}
+		m.Workers[n.Name] = n
+		if _, ok := m.WorkerTaskMap[n.Name]; !ok {
+			m.WorkerTaskMap[n.Name] = []uuid.UUID{}
+		}
+	}
+
+	for id, t := range m.TasksDb {
+		if _, placed := m.TaskWorkerMap[id]; placed || (t.State != task.Pending && t.State != task.Lost) {
+			continue
+		}
+
+		pending := *t
+		pending.State = task.Pending
+		pending.Runtime = task.RuntimeInfo{}
+		m.Pending.Enqueue(task.TaskEvent{
+			ID:        uuid.New(),
+			State:     task.Scheduled,
+			TimeStamp: now,
+			Task:      pending,
+		})
+	}
+
+	log.Printf("Restored %d tasks and %d workers from the store", len(m.TasksDb), len(m.Workers))
+	return nil
 }
 
 // SelectWorker is responsible for checking the needs of the tasks and check which worker should(is capable) of handling this.
-func (m *Manager) SelectWorker() {
-	fmt.Println("Selecting worker")
+// The ready workers are handed to the task's scheduler, which filters, scores and picks among them. The caller must hold m.mu.
+func (m *Manager) SelectWorker(t task.Task) (*node.Node, error) {
+	s, err := m.schedulerFor(t)
+	if err != nil {
+		return nil, err
+	}
+
+	m.updateAllocations()
+	ready := m.readyWorkers()
+	if len(ready) == 0 {
+		return nil, errors.New("no workers available")
+	}
+
+	candidates := s.SelectCandidateNodes(t, ready)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("none of the %d ready workers can run the task", len(ready))
+	}
+
+	scores := s.Score(t, candidates)
+	n := s.Pick(scores, candidates)
+	if n == nil {
+		return nil, fmt.Errorf("the %s scheduler picked no worker", s.Name())
+	}
+	log.Printf("Scheduler %s placed task %v on worker %s out of %d candidates, scores: %v", s.Name(), t.ID, n.Name, len(candidates), scores)
+	return n, nil
 }
 
+// DryRun runs the select, score and pick steps of the task's scheduler over the workers without placing
+// the task, and explains the outcome for every worker. The workers that aren't ready are reported as
+// rejected without being handed to the scheduler. Preemption isn't tried.
+func (m *Manager) DryRun(t task.Task) (scheduler.Explanation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.validateTask(t); err != nil {
+		return scheduler.Explanation{}, err
+	}
+	s, err := m.schedulerFor(t)
+	if err != nil {
+		return scheduler.Explanation{}, err
+	}
+
+	m.updateAllocations()
+	return scheduler.Explain(s, t, m.sortedWorkers()), nil
+}
+
+// schedulerFor returns the scheduler that places the task: the one named by its Strategy, or the
+// manager's Scheduler when it names none. The caller must hold m.mu.
+func (m *Manager) schedulerFor(t task.Task) (scheduler.Scheduler, error) {
+	if t.Strategy == "" || t.Strategy == m.Scheduler.Name() {
+		return m.Scheduler, nil
+	}
+	if s, ok := m.Schedulers[t.Strategy]; ok {
+		return s, nil
+	}
+
+	s, err := scheduler.New(t.Strategy)
+	if err != nil {
+		return nil, err
+	}
+	m.Schedulers[t.Strategy] = s
+	return s, nil
+}
+
+// UpdateTasks asks every worker for the tasks it is running and copies the observed state back into TasksDb.
 func (m *Manager) UpdateTasks() {
-	fmt.Println("Updating tasks")
+	m.mu.Lock()
+	apis := make(map[string]string)
+	for name, n := range m.Workers {
+		if n.Status != node.Gone {
+			apis[name] = n.Api
+		}
+	}
+	m.mu.Unlock()
+
+	for w, api := range apis {
+		log.Printf("Checking worker %v for task updates", w)
+		url := fmt.Sprintf("http://%s/tasks", api)
+		resp, err := http.Get(url)
+		if err != nil {
+			log.Printf("Error connecting to %v: %v", w, err)
+			continue
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			log.Printf("Error sending request to %v: unexpected status %d", w, resp.StatusCode)
+			resp.Body.Close()
+			continue
+		}
+
+		var tasks []*task.Task
+		err = json.NewDecoder(resp.Body).Decode(&tasks)
+		resp.Body.Close()
+		if err != nil {
+			log.Printf("Error unmarshalling tasks from %v: %v", w, err)
+			continue
+		}
+
+		var stale []uuid.UUID
+		var freed bool
+		m.mu.Lock()
+		for _, t := range tasks {
+			log.Printf("Attempting to update task %v", t.ID)
+			persisted, ok := m.TasksDb[t.ID]
+			if !ok {
+				log.Printf("Task with ID %s not found", t.ID)
+				continue
+			}
+
+			if owner := m.TaskWorkerMap[t.ID]; owner != w {
+				// the task was rescheduled while this worker was gone, so its copy here must not keep running.
+				if t.State == task.Scheduled || t.State == task.Running {
+					log.Printf("Task %v on worker %v now belongs to %q, stopping the stale copy", t.ID, w, owner)
+					stale = append(stale, t.ID)
+				}
+				continue
+			}
+
+			if (persisted.State == task.Scheduled || persisted.State == task.Running) && (t.State == task.Completed || t.State == task.Failed) {
+				freed = true
+			}
+			persisted.State = t.State
+			persisted.StartTime = t.StartTime
+			persisted.FinishTime = t.FinishTime
+			persisted.Runtime.ContainerId = t.Runtime.ContainerId
+			persisted.Health = t.Health
+			persisted.HealthHistory = t.HealthHistory
+			m.saveTask(persisted)
+		}
+		if freed {
+			m.retryUnschedulable(fmt.Sprintf("Tasks finished on worker %s", w))
+		}
+		m.mu.Unlock()
+
+		for _, id := range stale {
+			m.stopTask(api, id)
+		}
+	}
 }
 
+// SendWork takes the next task event off the Pending queue and hands it to a worker.
+// Events for tasks the manager hasn't placed yet are sent to a newly selected worker,
+// while events for placed tasks (i.e. stop requests) go to the worker already running them.
 func (m *Manager) SendWork() {
-	fmt.Println("Sending work to workers")
+	m.mu.Lock()
+	if m.Pending.Len() == 0 {
+		m.mu.Unlock()
+		log.Println("No work in the queue")
+		return
+	}
+
+	te, _ := m.Pending.Dequeue()
+
+	if w, placed := m.TaskWorkerMap[te.Task.ID]; placed {
+		persisted, known := m.TasksDb[te.Task.ID]
+		n, found := m.Workers[w]
+		if !known || !found {
+			log.Printf("Task %v is assigned to %v but the manager has no record of the task or the worker, dropping the assignment", te.Task.ID, w)
+			m.unassign(te.Task.ID)
+			m.mu.Unlock()
+			return
+		}
+		if te.State != task.Completed || !task.ValidateStateTransition(persisted.State, te.State) {
+			m.mu.Unlock()
+			log.Printf("Invalid request: task %v is in state %v and cannot transition to %v", te.Task.ID, persisted.State, te.State)
+			return
+		}
+		m.recordEvent(te)
+		api := n.Api
+		m.mu.Unlock()
+
+		m.stopTask(api, te.Task.ID)
+		return
+	}
+
+	if persisted, ok := m.TasksDb[te.Task.ID]; ok && persisted.DesiredState == task.Completed {
+		// the task was stopped before it reached a worker, so there's nothing to stop there.
+		if persisted.State != task.Completed {
+			persisted.State = task.Completed
+			persisted.FinishTime = time.Now().UTC()
+			m.saveTask(persisted)
+			m.recordEvent(te)
+		}
+		m.mu.Unlock()
+		log.Printf("Task %v was stopped before it was placed on a worker", te.Task.ID)
+		return
+	}
+
+	if te.Task.Group != "" {
+		m.gather(te)
+		return
+	}
+
+	n, err := m.SelectWorker(te.Task)
+	var preempted []stopRequest
+	if err != nil {
+		if n, preempted = m.preempt(te.Task); n == nil {
+			m.markUnschedulable(te)
+			m.mu.Unlock()
+			return
+		}
+		te.Reason = fmt.Sprintf("placed on worker %s by preempting %d lower priority tasks", n.Name, len(preempted))
+	}
+	m.recordEvent(te)
+	m.Unschedulable.Forget(te.Task.ID)
+
+	t := te.Task
+	t.State = task.Scheduled
+	t.PendingReason = ""
+	te.Task = t
+
+	w := n.Name
+	m.assign(t.ID, w)
+	m.TasksDb[t.ID] = &t
+	m.saveTask(&t)
+	api := n.Api
+	m.mu.Unlock()
+	log.Printf("Pulled %v off pending queue and assigned it to %v", t.ID, w)
+
+	m.stopTasks(preempted)
+
+	err = m.startTask(api, te)
+	switch {
+	case err == nil:
+		log.Printf("Worker %v accepted task %v", w, t.ID)
+	case errors.Is(err, errRejected):
+		log.Printf("Worker %v rejected task %v: %v", w, t.ID, err)
+		m.mu.Lock()
+		// the worker won't take the task however often it's sent, so Reconcile mustn't send it again.
+		m.unassign(t.ID)
+		t.State = task.Failed
+		m.saveTask(&t)
+		m.mu.Unlock()
+	default:
+		log.Printf("Error sending task %v to %v: %v", t.ID, w, err)
+		m.mu.Lock()
+		m.unassign(t.ID)
+		t.State = task.Pending
+		m.saveTask(&t)
+		m.Pending.Enqueue(te)
+		m.mu.Unlock()
+	}
+}
+
+// markUnschedulable records why no worker can take the task and keeps it aside until its backoff expires,
+// so that the tasks queued behind it are placed meanwhile. The caller must hold m.mu.
+func (m *Manager) markUnschedulable(te task.TaskEvent) {
+	reason := m.unschedulableReason(te.Task)
+	if t, ok := m.TasksDb[te.Task.ID]; ok {
+		t.PendingReason = reason
+		m.saveTask(t)
+	}
+	te.Task.PendingReason = reason
+
+	backoff := m.Unschedulable.Add(te, time.Now().UTC())
+	log.Printf("No worker can take task %v (%s), trying again in %v", te.Task.ID, reason, backoff)
+}
+
+// unschedulableReason sums up why the workers can't take the task, e.g. "0/3 nodes available: 2 insufficient
+// memory, 1 taint". The caller must hold m.mu.
+func (m *Manager) unschedulableReason(t task.Task) string {
+	s, err := m.schedulerFor(t)
+	if err != nil {
+		return err.Error()
+	}
+	m.updateAllocations()
+	return scheduler.Explain(s, t, m.sortedWorkers()).Summary()
+}
+
+// RetryUnschedulable puts the tasks whose backoff expired back on the Pending queue.
+func (m *Manager) RetryUnschedulable() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, te := range m.Unschedulable.Due(time.Now().UTC()) {
+		log.Printf("Trying to place task %v again", te.Task.ID)
+		m.Pending.Enqueue(te)
+	}
+}
+
+// retryUnschedulable puts every task waiting in Unschedulable back on the Pending queue without waiting for
+// its backoff, because the cluster changed in a way that may let it be placed. The caller must hold m.mu.
+func (m *Manager) retryUnschedulable(why string) {
+	if m.Unschedulable.Len() == 0 {
+		return
+	}
+	log.Printf("%s, trying to place the %d unschedulable tasks again", why, m.Unschedulable.Len())
+	for _, te := range m.Unschedulable.Flush() {
+		m.enqueue(te)
+	}
+}
+
+// ErrInvalidTask is returned by AddTask when the task can't be accepted as submitted.
+var ErrInvalidTask = errors.New("invalid task")
+
+// errRejected is returned by startTask when the worker was reached but refused the task.
+var errRejected = errors.New("task rejected by the worker")
+
+// startTask posts a task event to the worker whose API listens on api.
+func (m *Manager) startTask(api string, te task.TaskEvent) error {
+	data, err := json.Marshal(te)
+	if err != nil {
+		return fmt.Errorf("%w: unable to marshal task event %v: %v", errRejected, te.ID, err)
+	}
+
+	url := fmt.Sprintf("http://%s/tasks", api)
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		e := worker.ErrorResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+			return fmt.Errorf("%w: unexpected status %d", errRejected, resp.StatusCode)
+		}
+		return fmt.Errorf("%w: %s", errRejected, e.Message)
+	}
+	return nil
+}
+
+// stopTask asks the worker whose API listens on api to stop the task with the given ID.
+func (m *Manager) stopTask(api string, id uuid.UUID) {
+	url := fmt.Sprintf("http://%s/tasks/%s", api, id)
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		log.Printf("Error creating request to stop task %v: %v", id, err)
+		return
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("Error connecting to %v: %v", api, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		log.Printf("Error sending request to stop task %v: unexpected status %d", id, resp.StatusCode)
+		return
+	}
+	log.Printf("Task %v has been scheduled to be stopped", id)
+}
+
+// ProcessTasks sends the pending work to the workers as soon as it's queued, and every 10 seconds
+// tries again the work that couldn't be sent or placed.
+func (m *Manager) ProcessTasks() {
+	tick := time.NewTicker(10 * time.Second)
+	defer tick.Stop()
+	for {
+		log.Println("Processing any tasks in the queue")
+		m.RetryUnschedulable()
+		m.drainPending()
+		m.PlaceGroups()
+
+		select {
+		case <-m.notify:
+		case <-tick.C:
+		}
+	}
+}
+
+// drainPending hands every task event queued so far to SendWork. The events SendWork puts back, e.g.
+// because their worker couldn't be reached, wait for the next pass.
+func (m *Manager) drainPending() {
+	m.mu.Lock()
+	count := m.Pending.Len()
+	m.mu.Unlock()
+
+	for ; count > 0; count-- {
+		m.SendWork()
+	}
+}
+
+// enqueue puts a task event on the Pending queue and wakes ProcessTasks up to send it. The caller must hold m.mu.
+func (m *Manager) enqueue(te task.TaskEvent) {
+	m.Pending.Enqueue(te)
+	select {
+	case m.notify <- struct{}{}:
+	default: // ProcessTasks has already been notified and will drain the queue.
+	}
+}
+
+// UpdateTasksForever refreshes TasksDb from the workers every 15 seconds.
+func (m *Manager) UpdateTasksForever() {
+	for {
+		log.Println("Checking for task updates from workers")
+		m.UpdateTasks()
+		log.Println("Task updates completed, sleeping for 15 seconds")
+		time.Sleep(15 * time.Second)
+	}
+}
+
+// AddTask puts a task event on the Pending queue so that it's sent to a worker by SendWork.
+// The task is recorded in TasksDb straight away so it can be listed before it's placed.
+func (m *Manager) AddTask(te task.TaskEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.TasksDb[te.Task.ID]; ok {
+		return fmt.Errorf("task %v already exists", te.Task.ID)
+	}
+	if err := m.validateTask(te.Task); err != nil {
+		return err
+	}
+
+	te.Task.DesiredState = task.Running
+	t := te.Task
+	m.TasksDb[t.ID] = &t
+	if err := m.Store.PutTask(t); err != nil {
+		delete(m.TasksDb, t.ID)
+		return fmt.Errorf("unable to save task %v: %w", t.ID, err)
+	}
+	m.enqueue(te)
+	return nil
+}
+
+// validateTask returns an error wrapping ErrInvalidTask if the task can't be scheduled as it's written,
+// e.g. because it asks for an unknown strategy. The caller must hold m.mu.
+func (m *Manager) validateTask(t task.Task) error {
+	if err := t.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTask, err)
+	}
+	if _, err := m.schedulerFor(t); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTask, err)
+	}
+	for _, constraints := range [][]task.Constraint{t.Constraints, t.Affinities, t.AntiAffinities} {
+		for _, c := range constraints {
+			if err := c.Validate(); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidTask, err)
+			}
+		}
+	}
+	if t.Group != "" && t.GroupSize < 1 {
+		return fmt.Errorf("%w: task of group %s must give the size of its group", ErrInvalidTask, t.Group)
+	}
+	for _, tol := range t.Tolerations {
+		if err := tol.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidTask, err)
+		}
+	}
+	return nil
+}
+
+// StopTask queues a request to stop the task with the given ID.
+func (m *Manager) StopTask(id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.TasksDb[id]
+	if !ok {
+		return fmt.Errorf("task not found: %v", id)
+	}
+
+	t.DesiredState = task.Completed
+	m.saveTask(t)
+	m.Unschedulable.Forget(id)
+	m.Restarts.Forget(id)
+
+	stopped := *t
+	stopped.State = task.Completed
+	m.enqueue(task.TaskEvent{
+		ID:        uuid.New(),
+		State:     task.Completed,
+		TimeStamp: time.Now().UTC(),
+		Task:      stopped,
+	})
+	return nil
+}
+
+// GetTasks returns a copy of every task the manager knows about.
+func (m *Manager) GetTasks() []task.Task {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tasks := make([]task.Task, 0, len(m.TasksDb))
+	for _, t := range m.TasksDb {
+		tasks = append(tasks, *t)
+	}
+	return tasks
+}
+
+// GetTask returns a copy of the task with the given ID along with its event history.
+func (m *Manager) GetTask(id uuid.UUID) (task.Task, []task.TaskEvent, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.TasksDb[id]
+	if !ok {
+		return task.Task{}, nil, false
+	}
+
+	events := make([]task.TaskEvent, len(m.EventsDb[id]))
+	copy(events, m.EventsDb[id])
+	return *t, events, true
+}
+
+// assign records that the task with the given ID was placed on worker w. The caller must hold m.mu.
+func (m *Manager) assign(id uuid.UUID, w string) {
+	m.WorkerTaskMap[w] = append(m.WorkerTaskMap[w], id)
+	m.TaskWorkerMap[id] = w
+	if err := m.Store.PutAssignment(id, w); err != nil {
+		log.Printf("Error saving the assignment of task %v to %v: %v", id, w, err)
+	}
+}
+
+// unassign forgets the worker a task was assigned to, e.g. when the worker could not be reached.
+// The caller must hold m.mu.
+func (m *Manager) unassign(id uuid.UUID) {
+	w, ok := m.TaskWorkerMap[id]
+	if !ok {
+		return
+	}
+	delete(m.TaskWorkerMap, id)
+	if err := m.Store.DeleteAssignment(id); err != nil {
+		log.Printf("Error deleting the assignment of task %v: %v", id, err)
+	}
+
+	ids := m.WorkerTaskMap[w]
+	for i, tID := range ids {
+		if tID == id {
+			m.WorkerTaskMap[w] = append(ids[:i], ids[i+1:]...)
+			break
+		}
+	}
+}
+
+// saveTask writes the manager's view of a task through to the store. The caller must hold m.mu.
+func (m *Manager) saveTask(t *task.Task) {
+	if err := m.Store.PutTask(*t); err != nil {
+		log.Printf("Error saving task %v: %v", t.ID, err)
+	}
+}
+
+// recordEvent adds an event to the history of its task. The caller must hold m.mu.
+func (m *Manager) recordEvent(te task.TaskEvent) {
+	m.EventsDb[te.Task.ID] = append(m.EventsDb[te.Task.ID], te)
+	if err := m.Store.AppendEvent(te); err != nil {
+		log.Printf("Error saving event %v of task %v: %v", te.ID, te.Task.ID, err)
+	}
+}
+
+// stopRequest is a task the manager took off a worker, e.g. to make room for a higher priority task,
+// and that must be stopped there.
+type stopRequest struct {
+	id  uuid.UUID
+	api string // api is the address of the worker the task must be stopped on.
+}
+
+// stopTasks stops every task of the requests on its worker.
+func (m *Manager) stopTasks(stops []stopRequest) {
+	for _, s := range stops {
+		m.stopTask(s.api, s.id)
+	}
+}
+
+// preempt makes room for a task no worker can take by putting lower priority tasks of a single worker
+// back on the Pending queue, recording each preemption in EventsDb. It returns the worker the task can
+// now be placed on and the tasks to stop there, or nil when preempting wouldn't help. The caller must hold m.mu.
+func (m *Manager) preempt(t task.Task) (*node.Node, []stopRequest) {
+	s, err := m.schedulerFor(t)
+	if err != nil {
+		return nil, nil
+	}
+
+	running := make(map[string][]task.Task)
+	for id, w := range m.TaskWorkerMap {
+		if r, ok := m.TasksDb[id]; ok && (r.State == task.Scheduled || r.State == task.Running) {
+			running[w] = append(running[w], *r)
+		}
+	}
+
+	n, victims := scheduler.SelectVictims(s, t, m.readyWorkers(), running)
+	if n == nil {
+		return nil, nil
+	}
+
+	now := time.Now().UTC()
+	var preempted []stopRequest
+	for _, v := range victims {
+		log.Printf("Preempting task %v (priority %d) on worker %s for task %v (priority %d)", v.ID, v.Priority, n.Name, t.ID, t.Priority)
+		victim := m.TasksDb[v.ID]
+		victim.State = task.Pending
+		preempted = append(preempted, stopRequest{id: v.ID, api: n.Api})
+		preempted = append(preempted, m.requeue(victim, now, fmt.Sprintf("preempted on worker %s by task %v with priority %d", n.Name, t.ID, t.Priority))...)
+	}
+	m.updateAllocations()
+	return n, preempted
 }
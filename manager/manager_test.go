@@ -0,0 +1,257 @@
+package manager
+
+import (
+	"orchestra/node"
+	"orchestra/task"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newTestManager creates a manager backed by a MemoryStore.
+func newTestManager(tb testing.TB) *Manager {
+	tb.Helper()
+	m, err := New(NewMemoryStore())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return m
+}
+
+// nodeFor returns a node with room for a few tasks, whose worker API is at api.
+func nodeFor(name, api string) node.Node {
+	return node.Node{Name: name, Api: api, Cores: 4, Memory: 8 << 30, Disk: 100 << 30}
+}
+
+// waitFor polls cond until it holds, and reports whether it did within a few seconds.
+func waitFor(cond func() bool) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
+
+func TestSendWorkDropsDanglingAssignments(t *testing.T) {
+	tests := []struct {
+		name       string
+		keepTask   bool
+		keepWorker bool
+	}{
+		{"task record missing", false, true},
+		{"worker record missing", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t)
+			_, _, api := newTestWorker(t, "w1")
+			if err := m.RegisterWorker(nodeFor("w1", api)); err != nil {
+				t.Fatal(err)
+			}
+
+			id := uuid.New()
+			running := task.Task{ID: id, State: task.Running, DesiredState: task.Running}
+			m.mu.Lock()
+			m.assign(id, "w1")
+			if tt.keepTask {
+				m.TasksDb[id] = &running
+			}
+			if !tt.keepWorker {
+				delete(m.Workers, "w1")
+			}
+			stopped := running
+			stopped.State = task.Completed
+			m.Pending.Enqueue(task.TaskEvent{ID: uuid.New(), State: task.Completed, TimeStamp: time.Now(), Task: stopped})
+			m.mu.Unlock()
+
+			m.SendWork()
+
+			if _, ok := m.TaskWorkerMap[id]; ok {
+				t.Error("the dangling assignment was kept")
+			}
+			if assignments, _ := m.Store.ListAssignments(); len(assignments) != 0 {
+				t.Errorf("store holds %d assignments, want the dangling one deleted", len(assignments))
+			}
+		})
+	}
+}
+
+func TestProcessTasksSendsBurstsAtOnce(t *testing.T) {
+	m := newTestManager(t)
+	w, _, api := newTestWorker(t, "w1")
+	if err := m.RegisterWorker(nodeFor("w1", api)); err != nil {
+		t.Fatal(err)
+	}
+	go m.ProcessTasks()
+
+	// the first pass runs right away; the burst arrives while the loop waits for its next tick.
+	time.Sleep(10 * time.Millisecond)
+	const burst = 5
+	for i := 0; i < burst; i++ {
+		te := task.TaskEvent{ID: uuid.New(), State: task.Scheduled, TimeStamp: time.Now(), Task: task.Task{ID: uuid.New(), Image: "alpine:3"}}
+		if err := m.AddTask(te); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sent := waitFor(func() bool {
+		w.RunTask()
+		return len(w.GetTasks()) == burst
+	})
+	if !sent {
+		t.Fatalf("worker got %d of the %d tasks without waiting for the next tick", len(w.GetTasks()), burst)
+	}
+}
+
+func TestSchedulerFor(t *testing.T) {
+	m := newTestManager(t)
+
+	for _, strategy := range []string{"", "roundrobin"} {
+		if s, err := m.schedulerFor(task.Task{Strategy: strategy}); err != nil || s != m.Scheduler {
+			t.Errorf("schedulerFor(%q) = %v, %v, want the manager's scheduler", strategy, s, err)
+		}
+	}
+
+	s, err := m.schedulerFor(task.Task{Strategy: "binpack"})
+	if err != nil || s.Name() != "binpack" {
+		t.Fatalf("schedulerFor(binpack) = %v, %v", s, err)
+	}
+	if again, _ := m.schedulerFor(task.Task{Strategy: "binpack"}); again != s {
+		t.Error("schedulerFor(binpack) created a second scheduler, want the first one reused")
+	}
+
+	if _, err := m.schedulerFor(task.Task{Strategy: "fastest"}); err == nil {
+		t.Error("schedulerFor() error = nil for an unknown strategy")
+	}
+}
+
+// TestSelectWorkerWithStrategy checks that a task asking for another strategy than the manager's is
+// placed by it, given what the tasks already placed allocate.
+func TestSelectWorkerWithStrategy(t *testing.T) {
+	m := newTestManager(t)
+	for _, name := range []string{"w1", "w2"} {
+		if err := m.RegisterWorker(nodeFor(name, name+":5556")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	placed := task.Task{ID: uuid.New(), State: task.Running, DesiredState: task.Running, Memory: 4 << 30}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.TasksDb[placed.ID] = &placed
+	m.assign(placed.ID, "w2")
+
+	for strategy, want := range map[string]string{"binpack": "w2", "spread": "w1"} {
+		n, err := m.SelectWorker(task.Task{ID: uuid.New(), Strategy: strategy, Memory: 1 << 30})
+		if err != nil || n.Name != want {
+			t.Errorf("SelectWorker() with strategy %s = %v, %v, want %s", strategy, n, err, want)
+		}
+	}
+}
+
+// TestSetTaintsEvictsTasks checks that a NoExecute taint stops the tasks of the worker that don't tolerate it
+// and places them again elsewhere, while the tasks that tolerate it stay.
+func TestSetTaintsEvictsTasks(t *testing.T) {
+	m := newTestManager(t)
+	w1, rt, api := newTestWorker(t, "w1")
+	if err := m.RegisterWorker(nodeFor("w1", api)); err != nil {
+		t.Fatal(err)
+	}
+	tolerant := runOn(t, m, w1, task.Task{ID: uuid.New(), Image: "nginx:1", Tolerations: []task.Toleration{{Key: "dedicated", Operator: task.Exists}}})
+	intolerant := runOn(t, m, w1, task.Task{ID: uuid.New(), Image: "nginx:1"})
+
+	w2, _, api2 := newTestWorker(t, "w2")
+	if err := m.RegisterWorker(nodeFor("w2", api2)); err != nil {
+		t.Fatal(err)
+	}
+
+	// NoSchedule only keeps new tasks off the worker.
+	if _, err := m.SetTaints("w1", []node.Taint{{Key: "dedicated", Value: "infra", Effect: node.NoSchedule}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.TaskWorkerMap[intolerant.ID]; got != "w1" {
+		t.Fatalf("task on %q after a NoSchedule taint, want it left on w1", got)
+	}
+
+	if _, err := m.SetTaints("w1", []node.Taint{{Key: "dedicated", Value: "infra", Effect: node.NoExecute}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.TaskWorkerMap[intolerant.ID]; ok || m.Pending.Len() != 1 {
+		t.Fatalf("task assigned to %q with %d pending after a NoExecute taint, want it requeued", m.TaskWorkerMap[intolerant.ID], m.Pending.Len())
+	}
+	if got := m.TaskWorkerMap[tolerant.ID]; got != "w1" {
+		t.Errorf("tolerant task on %q, want it left on w1", got)
+	}
+
+	// the evicted task is stopped on w1 and placed on w2.
+	if result := w1.RunTask(); result.Error != nil {
+		t.Fatalf("w1 RunTask() error = %v", result.Error)
+	}
+	if got, _ := w1.GetTask(intolerant.ID); got.State != task.Completed || len(rt.Containers()) != 1 {
+		t.Errorf("evicted task is %v on w1 with %d containers left, want it stopped", got.State, len(rt.Containers()))
+	}
+	m.SendWork()
+	if got := m.TaskWorkerMap[intolerant.ID]; got != "w2" {
+		t.Errorf("evicted task placed on %q, want w2", got)
+	}
+	if result := w2.RunTask(); result.Error != nil {
+		t.Errorf("w2 RunTask() error = %v", result.Error)
+	}
+}
+
+// TestSendWorkPreempts checks that a task no worker has room for takes the place of a lower priority task,
+// which is stopped and queued again, and that both histories tell why.
+func TestSendWorkPreempts(t *testing.T) {
+	m := newTestManager(t)
+	w, rt, api := newTestWorker(t, "w1")
+	if err := m.RegisterWorker(nodeFor("w1", api)); err != nil {
+		t.Fatal(err)
+	}
+	low := runOn(t, m, w, task.Task{ID: uuid.New(), Image: "nginx:1", Memory: 6 << 30})
+
+	// a task of the same priority waits instead.
+	same := task.Task{ID: uuid.New(), Image: "nginx:1", Memory: 4 << 30}
+	if err := m.AddTask(task.TaskEvent{ID: uuid.New(), State: task.Scheduled, TimeStamp: time.Now(), Task: same}); err != nil {
+		t.Fatal(err)
+	}
+	m.SendWork()
+	if _, placed := m.TaskWorkerMap[same.ID]; placed {
+		t.Fatal("task placed by preempting a task of the same priority")
+	}
+
+	high := task.Task{ID: uuid.New(), Image: "nginx:1", Memory: 4 << 30, Priority: 10}
+	if err := m.AddTask(task.TaskEvent{ID: uuid.New(), State: task.Scheduled, TimeStamp: time.Now(), Task: high}); err != nil {
+		t.Fatal(err)
+	}
+	m.SendWork()
+
+	if got := m.TaskWorkerMap[high.ID]; got != "w1" {
+		t.Fatalf("high priority task on %q, want w1", got)
+	}
+	if _, placed := m.TaskWorkerMap[low.ID]; placed || m.Pending.Len() != 1 {
+		t.Errorf("low priority task still assigned with %d pending, want it requeued", m.Pending.Len())
+	}
+	for _, id := range []uuid.UUID{low.ID, high.ID} {
+		_, events, _ := m.GetTask(id)
+		if last := events[len(events)-1]; last.Reason == "" {
+			t.Errorf("last event of task %v = %+v, want the preemption in its reason", id, last)
+		}
+	}
+
+	// the worker stops the preempted task before it starts the other.
+	for i := 0; i < 2; i++ {
+		if result := w.RunTask(); result.Error != nil {
+			t.Fatalf("worker RunTask() error = %v", result.Error)
+		}
+	}
+	if got, _ := w.GetTask(low.ID); got.State != task.Completed {
+		t.Errorf("preempted task is %v on the worker, want it stopped", got.State)
+	}
+	if got, _ := w.GetTask(high.ID); got.State != task.Running || len(rt.Containers()) != 1 {
+		t.Errorf("high priority task is %v on the worker with %d containers, want it alone running", got.State, len(rt.Containers()))
+	}
+}
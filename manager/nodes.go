@@ -0,0 +1,313 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"orchestra/node"
+	"orchestra/scheduler"
+	"orchestra/task"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultNotReadyAfter is how long a worker may stay silent before it stops receiving new tasks.
+	DefaultNotReadyAfter = 30 * time.Second
+
+	// DefaultGoneAfter is how long a worker may stay silent before the manager gives up on it.
+	DefaultGoneAfter = 2 * time.Minute
+)
+
+// ErrUnknownWorker is returned when a heartbeat arrives from a worker that hasn't registered.
+var ErrUnknownWorker = errors.New("unknown worker")
+
+// RegisterWorker adds a worker to the cluster, or brings back a worker that registers again after a restart.
+func (m *Manager) RegisterWorker(n node.Node) error {
+	if n.Name == "" || n.Api == "" {
+		return errors.New("a worker needs a name and an API address to register")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing, ok := m.Workers[n.Name]; ok {
+		// keep what the manager accounted for on the node.
+		n.MemoryAllocated = existing.MemoryAllocated
+		n.DiskAllocated = existing.DiskAllocated
+		n.CPUAllocated = existing.CPUAllocated
+		n.Taints = existing.Taints
+	}
+	n.Status = node.Ready
+	n.LastHeartbeat = time.Now().UTC()
+	m.Workers[n.Name] = &n
+	m.saveNode(&n)
+	if _, ok := m.WorkerTaskMap[n.Name]; !ok {
+		m.WorkerTaskMap[n.Name] = []uuid.UUID{}
+	}
+
+	log.Printf("Worker %s registered from %s", n.Name, n.Api)
+	m.retryUnschedulable(fmt.Sprintf("Worker %s joined", n.Name))
+	return nil
+}
+
+// Heartbeat records that the named worker is alive and refreshes what it reported about itself.
+func (m *Manager) Heartbeat(name string, n node.Node) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.Workers[name]
+	if !ok || existing.Status == node.Gone {
+		return ErrUnknownWorker
+	}
+
+	wasReady := existing.Status == node.Ready
+	changed := existing.Cores != n.Cores || existing.Memory != n.Memory || existing.Disk != n.Disk ||
+		!maps.Equal(existing.Labels, n.Labels) || existing.Reserved != n.Reserved || existing.Overcommit != n.Overcommit
+	existing.Cores = n.Cores
+	existing.Memory = n.Memory
+	existing.Disk = n.Disk
+	existing.MemoryAvailable = n.MemoryAvailable
+	existing.Load = n.Load
+	existing.Labels = n.Labels
+	existing.Reserved = n.Reserved
+	existing.Overcommit = n.Overcommit
+	existing.TaskCount = n.TaskCount
+	existing.Status = node.Ready
+	existing.LastHeartbeat = time.Now().UTC()
+	switch {
+	case !wasReady:
+		log.Printf("Worker %s is ready again", name)
+		m.saveNode(existing)
+		m.retryUnschedulable(fmt.Sprintf("Worker %s is ready", name))
+	case changed:
+		log.Printf("Worker %s reported new capacity or labels", name)
+		m.saveNode(existing)
+		m.retryUnschedulable(fmt.Sprintf("Worker %s changed", name))
+	}
+	return nil
+}
+
+// SetTaints replaces the taints of the named worker. The tasks on the worker that don't tolerate
+// its new NoExecute taints are evicted: they're stopped there and placed again elsewhere.
+func (m *Manager) SetTaints(name string, taints []node.Taint) (node.Node, error) {
+	m.mu.Lock()
+	n, ok := m.Workers[name]
+	if !ok {
+		m.mu.Unlock()
+		return node.Node{}, ErrUnknownWorker
+	}
+
+	n.Taints = taints
+	m.saveNode(n)
+	log.Printf("Worker %s is now tainted with %v", name, taints)
+
+	evicted := m.evictTasks(name)
+	m.retryUnschedulable(fmt.Sprintf("Taints of worker %s changed", name))
+	updated := *n
+	m.mu.Unlock()
+
+	m.stopTasks(evicted)
+	return updated, nil
+}
+
+// evictTasks puts the active tasks of a worker that don't tolerate its NoExecute taints back on the
+// Pending queue, and returns them so that they're stopped on the worker. The caller must hold m.mu.
+func (m *Manager) evictTasks(worker string) []stopRequest {
+	var evicted []stopRequest
+	now := time.Now().UTC()
+	for _, id := range append([]uuid.UUID{}, m.WorkerTaskMap[worker]...) {
+		t, ok := m.TasksDb[id]
+		if !ok || (t.State != task.Scheduled && t.State != task.Running) {
+			continue
+		}
+
+		for _, taint := range m.Workers[worker].Taints {
+			if taint.Effect != node.NoExecute || t.Tolerates(taint) {
+				continue
+			}
+
+			log.Printf("Evicting task %v from worker %s, which is tainted with %s", id, worker, taint)
+			evicted = append(evicted, stopRequest{id: id, api: m.Workers[worker].Api})
+			t.State = task.Pending
+			evicted = append(evicted, m.requeue(t, now, fmt.Sprintf("evicted from worker %s, which is tainted with %s", worker, taint))...)
+			break
+		}
+	}
+	return evicted
+}
+
+// GetNodes returns a copy of every worker node the manager knows about, sorted by name,
+// with the resources currently allocated to its tasks.
+func (m *Manager) GetNodes() []node.Node {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.updateAllocations()
+
+	nodes := make([]node.Node, 0, len(m.Workers))
+	for _, n := range m.Workers {
+		nodes = append(nodes, *n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes
+}
+
+// CheckWorkers updates the status of each worker based on how long ago its last heartbeat arrived.
+func (m *Manager) CheckWorkers() {
+	m.mu.Lock()
+	var stops []stopRequest
+	defer func() {
+		m.mu.Unlock()
+		m.stopTasks(stops)
+	}()
+
+	now := time.Now().UTC()
+	for name, n := range m.Workers {
+		silence := now.Sub(n.LastHeartbeat)
+		switch {
+		case silence > m.GoneAfter:
+			if n.Status != node.Gone {
+				log.Printf("Worker %s has not sent a heartbeat for %v, marking it gone", name, silence.Round(time.Second))
+				n.Status = node.Gone
+				m.saveNode(n)
+				stops = append(stops, m.rescheduleTasks(name)...)
+			}
+		case silence > m.NotReadyAfter:
+			if n.Status != node.NotReady {
+				log.Printf("Worker %s has not sent a heartbeat for %v, marking it not ready", name, silence.Round(time.Second))
+				n.Status = node.NotReady
+				m.saveNode(n)
+			}
+		}
+	}
+}
+
+// CheckWorkersForever runs CheckWorkers every interval.
+func (m *Manager) CheckWorkersForever(interval time.Duration) {
+	for {
+		m.CheckWorkers()
+		time.Sleep(interval)
+	}
+}
+
+// saveNode writes a worker's node through to the store. The caller must hold m.mu.
+func (m *Manager) saveNode(n *node.Node) {
+	if err := m.Store.PutNode(*n); err != nil {
+		log.Printf("Error saving worker %s: %v", n.Name, err)
+	}
+}
+
+// updateAllocations recomputes the memory, disk and CPU each worker has reserved for the tasks placed on it
+// that are scheduled or running. The caller must hold m.mu.
+func (m *Manager) updateAllocations() {
+	for _, n := range m.Workers {
+		n.MemoryAllocated = 0
+		n.DiskAllocated = 0
+		n.CPUAllocated = 0
+	}
+	for id, w := range m.TaskWorkerMap {
+		n, ok := m.Workers[w]
+		t, found := m.TasksDb[id]
+		if !ok || !found || (t.State != task.Scheduled && t.State != task.Running) {
+			continue
+		}
+		n.MemoryAllocated += t.Memory
+		n.DiskAllocated += t.Disk * scheduler.Gigabyte
+		n.CPUAllocated += t.CPU
+	}
+}
+
+// readyWorkers returns the workers that can be given tasks, sorted by name. The caller must hold m.mu.
+func (m *Manager) readyWorkers() []*node.Node {
+	var ready []*node.Node
+	for _, n := range m.sortedWorkers() {
+		if n.Status == node.Ready {
+			ready = append(ready, n)
+		}
+	}
+	return ready
+}
+
+// sortedWorkers returns every worker, sorted by name. The caller must hold m.mu.
+func (m *Manager) sortedWorkers() []*node.Node {
+	workers := make([]*node.Node, 0, len(m.Workers))
+	for _, n := range m.Workers {
+		workers = append(workers, n)
+	}
+	sort.Slice(workers, func(i, j int) bool { return workers[i].Name < workers[j].Name })
+	return workers
+}
+
+// rescheduleTasks marks the active tasks of a lost worker as Lost and puts them back on the Pending
+// queue so they're placed on a healthy worker. It returns the tasks to stop on other workers, i.e. the
+// other members of the groups of the lost tasks. The caller must hold m.mu.
+func (m *Manager) rescheduleTasks(worker string) []stopRequest {
+	var stops []stopRequest
+	now := time.Now().UTC()
+	for _, id := range append([]uuid.UUID{}, m.WorkerTaskMap[worker]...) {
+		t, ok := m.TasksDb[id]
+		if !ok || (t.State != task.Scheduled && t.State != task.Running) {
+			continue
+		}
+
+		t.State = task.Lost
+		stops = append(stops, m.requeue(t, now, fmt.Sprintf("worker %s is gone", worker))...)
+		log.Printf("Task %v was lost with worker %s, rescheduling it", id, worker)
+	}
+	return stops
+}
+
+// requeue takes a task off its worker, recording its current state as an event for the given reason,
+// and puts it back on the Pending queue to be placed again. The other active members of the task's
+// group, if it has one, are requeued with it since a group only runs whole; they're returned so that
+// they're stopped on their workers. The caller must hold m.mu.
+func (m *Manager) requeue(t *task.Task, now time.Time, reason string) []stopRequest {
+	m.requeueOne(t, now, reason)
+	if t.Group == "" {
+		return nil
+	}
+
+	var stops []stopRequest
+	for id, w := range m.TaskWorkerMap {
+		member, ok := m.TasksDb[id]
+		if !ok || member.Group != t.Group || (member.State != task.Scheduled && member.State != task.Running) {
+			continue
+		}
+		if n, ok := m.Workers[w]; ok {
+			stops = append(stops, stopRequest{id: id, api: n.Api})
+		}
+		member.State = task.Pending
+		m.requeueOne(member, now, fmt.Sprintf("requeued with task %v of group %s", t.ID, t.Group))
+	}
+	return stops
+}
+
+// requeueOne is requeue for a single task. The caller must hold m.mu.
+func (m *Manager) requeueOne(t *task.Task, now time.Time, reason string) {
+	t.FinishTime = now
+	m.saveTask(t)
+	m.recordEvent(task.TaskEvent{
+		ID:        uuid.New(),
+		State:     t.State,
+		TimeStamp: now,
+		Task:      *t,
+		Reason:    reason,
+	})
+	m.unassign(t.ID)
+
+	rescheduled := *t
+	rescheduled.State = task.Pending
+	rescheduled.StartTime = time.Time{}
+	rescheduled.FinishTime = time.Time{}
+	rescheduled.Runtime = task.RuntimeInfo{}
+	m.enqueue(task.TaskEvent{
+		ID:        uuid.New(),
+		State:     task.Scheduled,
+		TimeStamp: now,
+		Task:      rescheduled,
+	})
+}
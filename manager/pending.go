@@ -0,0 +1,71 @@
+package manager
+
+import (
+	"container/heap"
+	"orchestra/task"
+)
+
+// PendingQueue holds the task events waiting to be sent to workers. Stop requests come first, since they
+// free resources, then the events of the tasks with the highest Priority; events that rank the same are
+// dequeued in the order they were enqueued. The zero value is an empty queue.
+type PendingQueue struct {
+	items pendingItems
+	seq   uint64 // seq numbers the events in the order they're enqueued.
+}
+
+type pendingItem struct {
+	te  task.TaskEvent
+	seq uint64
+}
+
+// Enqueue adds a task event to the queue.
+func (q *PendingQueue) Enqueue(te task.TaskEvent) {
+	q.seq++
+	heap.Push(&q.items, pendingItem{te: te, seq: q.seq})
+}
+
+// Dequeue removes the first task event from the queue. It reports false when the queue is empty.
+func (q *PendingQueue) Dequeue() (task.TaskEvent, bool) {
+	if len(q.items) == 0 {
+		return task.TaskEvent{}, false
+	}
+	return heap.Pop(&q.items).(pendingItem).te, true
+}
+
+// Len returns the number of task events in the queue.
+func (q *PendingQueue) Len() int {
+	return len(q.items)
+}
+
+// pendingItems implements heap.Interface.
+type pendingItems []pendingItem
+
+func (p pendingItems) Len() int {
+	return len(p)
+}
+
+func (p pendingItems) Less(i, j int) bool {
+	a, b := p[i], p[j]
+	if stopA, stopB := a.te.State == task.Completed, b.te.State == task.Completed; stopA != stopB {
+		return stopA
+	}
+	if a.te.Task.Priority != b.te.Task.Priority {
+		return a.te.Task.Priority > b.te.Task.Priority
+	}
+	return a.seq < b.seq
+}
+
+func (p pendingItems) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+}
+
+func (p *pendingItems) Push(x any) {
+	*p = append(*p, x.(pendingItem))
+}
+
+func (p *pendingItems) Pop() any {
+	old := *p
+	item := old[len(old)-1]
+	*p = old[:len(old)-1]
+	return item
+}
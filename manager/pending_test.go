@@ -0,0 +1,38 @@
+package manager
+
+import (
+	"orchestra/task"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPendingQueueOrder(t *testing.T) {
+	event := func(name string, state task.State, priority int) task.TaskEvent {
+		return task.TaskEvent{ID: uuid.New(), State: state, Task: task.Task{ID: uuid.New(), Name: name, Priority: priority}}
+	}
+
+	var q PendingQueue
+	for _, te := range []task.TaskEvent{
+		event("low", task.Scheduled, 0),
+		event("high", task.Scheduled, 10),
+		event("stop-low", task.Completed, 0),
+		event("low-2", task.Scheduled, 0),
+		event("mid", task.Scheduled, 5),
+		event("stop-high", task.Completed, 10),
+		event("high-2", task.Scheduled, 10),
+	} {
+		q.Enqueue(te)
+	}
+
+	want := []string{"stop-high", "stop-low", "high", "high-2", "mid", "low", "low-2"}
+	for _, name := range want {
+		te, ok := q.Dequeue()
+		if !ok || te.Task.Name != name {
+			t.Fatalf("Dequeue() = %q, %v, want %q", te.Task.Name, ok, name)
+		}
+	}
+	if _, ok := q.Dequeue(); ok || q.Len() != 0 {
+		t.Error("Dequeue() = true once every event was dequeued")
+	}
+}
@@ -0,0 +1,94 @@
+package manager
+
+import (
+	"log"
+	"orchestra/node"
+	"orchestra/task"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultReconcileInterval is how often the manager compares the desired state of the tasks with what the workers report.
+const DefaultReconcileInterval = 30 * time.Second
+
+// Reconcile closes the loop between the desired state of each placed task, kept in TasksDb, and the
+// state its worker last reported through UpdateTasks:
+//
+//  1. A task that should be running but stopped is started again, if its restart policy allows it and it
+//     has waited out its restart backoff.
+//  2. A task that should be stopped but is still running is stopped again.
+//
+// Tasks on workers that aren't ready are left alone; they're handled once the worker is back or gone.
+func (m *Manager) Reconcile() {
+	type correction struct {
+		api string
+		te  task.TaskEvent
+	}
+
+	var starts, stops []correction
+	m.mu.Lock()
+	now := time.Now().UTC()
+	for id, w := range m.TaskWorkerMap {
+		n, ok := m.Workers[w]
+		if !ok || n.Status != node.Ready {
+			continue
+		}
+
+		t, ok := m.TasksDb[id]
+		if !ok {
+			log.Printf("Task %v is assigned to %v but the manager has no record of it, dropping the assignment", id, w)
+			m.unassign(id)
+			continue
+		}
+		switch {
+		case t.ShouldRestart():
+			if ok, retryAt := m.Restarts.Allow(id, ranFor(t), now); !ok {
+				log.Printf("Task %v should be running but is in state %v, backing off until %v before starting it again", id, t.State, retryAt)
+				continue
+			}
+			log.Printf("Task %v should be running but is in state %v, starting it again on %v", id, t.State, w)
+			restarted := *t
+			restarted.State = task.Scheduled
+			te := task.TaskEvent{ID: uuid.New(), State: task.Scheduled, TimeStamp: now, Task: restarted}
+			m.recordEvent(te)
+			t.State = task.Scheduled
+			m.saveTask(t)
+			starts = append(starts, correction{api: n.Api, te: te})
+		case t.DesiredState == task.Completed && t.State == task.Running:
+			log.Printf("Task %v should be stopped but is still running, stopping it again on %v", id, w)
+			stopped := *t
+			stopped.State = task.Completed
+			te := task.TaskEvent{ID: uuid.New(), State: task.Completed, TimeStamp: now, Task: stopped}
+			m.recordEvent(te)
+			stops = append(stops, correction{api: n.Api, te: te})
+		}
+	}
+	m.mu.Unlock()
+
+	for _, c := range starts {
+		if err := m.startTask(c.api, c.te); err != nil {
+			log.Printf("Error restarting task %v: %v", c.te.Task.ID, err)
+		}
+	}
+	for _, c := range stops {
+		m.stopTask(c.api, c.te.Task.ID)
+	}
+}
+
+// ranFor returns how long the task ran before it last stopped, or 0 if it never started.
+func ranFor(t *task.Task) time.Duration {
+	if t.StartTime.IsZero() || t.FinishTime.Before(t.StartTime) {
+		return 0
+	}
+	return t.FinishTime.Sub(t.StartTime)
+}
+
+// ReconcileForever runs Reconcile every interval.
+func (m *Manager) ReconcileForever(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		log.Println("Reconciling the desired state of tasks with their observed state")
+		m.Reconcile()
+	}
+}
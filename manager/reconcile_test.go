@@ -0,0 +1,147 @@
+package manager
+
+import (
+	"orchestra/task"
+	"orchestra/worker"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// runOn places a task on a worker through the manager and runs it there.
+func runOn(tb testing.TB, m *Manager, w *worker.Worker, t task.Task) task.Task {
+	tb.Helper()
+	if err := m.AddTask(task.TaskEvent{ID: uuid.New(), State: task.Scheduled, TimeStamp: time.Now(), Task: t}); err != nil {
+		tb.Fatal(err)
+	}
+	m.SendWork()
+	if result := w.RunTask(); result.Error != nil {
+		tb.Fatalf("worker RunTask() error = %v", result.Error)
+	}
+	m.UpdateTasks()
+	got, _, _ := m.GetTask(t.ID)
+	if got.State != task.Running {
+		tb.Fatalf("task state = %v, want %v", got.State, task.Running)
+	}
+	return got
+}
+
+func TestReconcileRestartsStoppedTasks(t *testing.T) {
+	tests := []struct {
+		policy string
+		code   int
+		want   bool
+	}{
+		{"", 1, true},
+		{"", 0, false},
+		{"always", 0, true},
+		{"on-failure", 1, true},
+		{"no", 1, false},
+	}
+
+	for _, tt := range tests {
+		m := newTestManager(t)
+		w, rt, api := newTestWorker(t, "w1")
+		if err := m.RegisterWorker(nodeFor("w1", api)); err != nil {
+			t.Fatal(err)
+		}
+		running := runOn(t, m, w, task.Task{ID: uuid.New(), Image: "nginx:1", RestartPolicy: tt.policy})
+
+		if err := rt.Exit(running.Runtime.ContainerId, tt.code); err != nil {
+			t.Fatal(err)
+		}
+		w.UpdateTasks()
+		m.UpdateTasks()
+		m.Reconcile()
+		w.RunTask()
+		m.UpdateTasks()
+
+		got, _, _ := m.GetTask(running.ID)
+		if restarted := got.State == task.Running; restarted != tt.want {
+			t.Errorf("policy %q, exit %d: task state = %v after Reconcile, want restarted = %v", tt.policy, tt.code, got.State, tt.want)
+		}
+	}
+}
+
+func TestReconcileBacksOffRestarts(t *testing.T) {
+	m := newTestManager(t)
+	w, rt, api := newTestWorker(t, "w1")
+	if err := m.RegisterWorker(nodeFor("w1", api)); err != nil {
+		t.Fatal(err)
+	}
+	running := runOn(t, m, w, task.Task{ID: uuid.New(), Image: "nginx:1"})
+
+	crash := func() task.State {
+		got, _, _ := m.GetTask(running.ID)
+		if err := rt.Crash(got.Runtime.ContainerId); err != nil {
+			t.Fatal(err)
+		}
+		w.UpdateTasks()
+		m.UpdateTasks()
+		m.Reconcile()
+		w.RunTask()
+		m.UpdateTasks()
+		got, _, _ = m.GetTask(running.ID)
+		return got.State
+	}
+
+	if state := crash(); state != task.Running {
+		t.Fatalf("task state = %v after its first crash, want it restarted", state)
+	}
+	if state := crash(); state != task.Failed {
+		t.Errorf("task state = %v after crashing again within the backoff, want it left %v", state, task.Failed)
+	}
+}
+
+func TestReconcileStopsRunningTasks(t *testing.T) {
+	m := newTestManager(t)
+	w, rt, api := newTestWorker(t, "w1")
+	if err := m.RegisterWorker(nodeFor("w1", api)); err != nil {
+		t.Fatal(err)
+	}
+	running := runOn(t, m, w, task.Task{ID: uuid.New(), Image: "nginx:1"})
+
+	// the stop was recorded but never reached the worker.
+	m.mu.Lock()
+	m.TasksDb[running.ID].DesiredState = task.Completed
+	m.mu.Unlock()
+
+	m.Reconcile()
+	if result := w.RunTask(); result.Error != nil {
+		t.Fatalf("worker RunTask() error = %v", result.Error)
+	}
+	m.UpdateTasks()
+
+	if got, _, _ := m.GetTask(running.ID); got.State != task.Completed {
+		t.Errorf("task state = %v after Reconcile, want %v", got.State, task.Completed)
+	}
+	if containers := rt.Containers(); len(containers) != 0 {
+		t.Errorf("runtime has %d containers after the stop was sent again", len(containers))
+	}
+}
+
+func TestReconcileDropsUnknownAssignments(t *testing.T) {
+	m := newTestManager(t)
+	_, _, api := newTestWorker(t, "w1")
+	if err := m.RegisterWorker(nodeFor("w1", api)); err != nil {
+		t.Fatal(err)
+	}
+
+	id := uuid.New()
+	m.mu.Lock()
+	m.assign(id, "w1")
+	m.mu.Unlock()
+
+	m.Reconcile()
+
+	if _, ok := m.TaskWorkerMap[id]; ok {
+		t.Error("the assignment of an unknown task was kept")
+	}
+	if len(m.WorkerTaskMap["w1"]) != 0 {
+		t.Errorf("w1 still holds %v", m.WorkerTaskMap["w1"])
+	}
+	if assignments, _ := m.Store.ListAssignments(); len(assignments) != 0 {
+		t.Errorf("store holds %d assignments, want the unknown one deleted", len(assignments))
+	}
+}
@@ -0,0 +1,59 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"orchestra/worker"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// API is the front door of the cluster: users submit, inspect and stop tasks here
+// and the manager takes care of talking to the workers.
+type API struct {
+	Router  *chi.Mux
+	Port    int
+	Address string
+	Manager *Manager
+}
+
+func (a *API) APIError(w http.ResponseWriter, code int, errMsg string) {
+	log.Println(errMsg)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	e := worker.ErrorResponse{
+		HttpStatusCode: code,
+		Message:        errMsg,
+	}
+	json.NewEncoder(w).Encode(e)
+}
+
+func (a *API) initRouter() {
+	a.Router = chi.NewRouter()
+
+	a.Router.Route("/tasks", func(r chi.Router) {
+		r.Post("/", a.StartTaskHandler)
+		r.Get("/", a.GetTasksHandler)
+		r.Post("/dry-run", a.DryRunHandler)
+		r.Route("/{taskID}", func(r chi.Router) {
+			r.Get("/", a.GetTaskHandler)
+			r.Delete("/", a.StopTaskHandler)
+		})
+	})
+
+	a.Router.Route("/nodes", func(r chi.Router) {
+		r.Post("/", a.RegisterNodeHandler)
+		r.Get("/", a.GetNodesHandler)
+		r.Post("/{nodeName}/heartbeat", a.HeartbeatHandler)
+		r.Put("/{nodeName}/taints", a.SetTaintsHandler)
+	})
+}
+
+func (a *API) Start() {
+	a.initRouter()
+	addr := fmt.Sprintf("%s:%d", a.Address, a.Port)
+	fmt.Printf("Manager running on %s\n", addr)
+	http.ListenAndServe(addr, a.Router)
+}
@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultRestartBackoff is how long a task that was restarted must wait before it's restarted again.
+	DefaultRestartBackoff = 10 * time.Second
+
+	// DefaultMaxRestartBackoff caps how long a task that keeps stopping waits between restarts.
+	DefaultMaxRestartBackoff = 5 * time.Minute
+)
+
+// RestartBackoff spaces out the restarts of the tasks that keep stopping, so that a task that crashes on start
+// doesn't hammer its worker. The wait doubles with every restart, from InitialBackoff up to MaxBackoff, and starts
+// over once a task ran for at least MaxBackoff. The zero value backs off by the default durations.
+type RestartBackoff struct {
+	InitialBackoff time.Duration // InitialBackoff is the wait after the first restart of a task.
+	MaxBackoff     time.Duration // MaxBackoff caps the wait.
+
+	restarts map[uuid.UUID]restartRecord // restarts are keyed by task ID.
+}
+
+type restartRecord struct {
+	attempts  int
+	notBefore time.Time
+}
+
+// Allow reports whether a task that stopped after running for ran may be restarted at now, and records the
+// restart if it may. Otherwise it returns when it may.
+func (b *RestartBackoff) Allow(id uuid.UUID, ran time.Duration, now time.Time) (bool, time.Time) {
+	if b.restarts == nil {
+		b.restarts = make(map[uuid.UUID]restartRecord)
+	}
+
+	backoff, limit := b.InitialBackoff, b.MaxBackoff
+	if backoff <= 0 {
+		backoff = DefaultRestartBackoff
+	}
+	if limit <= 0 {
+		limit = DefaultMaxRestartBackoff
+	}
+
+	r := b.restarts[id]
+	if now.Before(r.notBefore) {
+		return false, r.notBefore
+	}
+	if ran >= limit {
+		r.attempts = 0
+	}
+	for i := 0; i < r.attempts && backoff < limit; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, limit)
+
+	b.restarts[id] = restartRecord{attempts: r.attempts + 1, notBefore: now.Add(backoff)}
+	return true, now
+}
+
+// Forget drops what's known of the restarts of a task, e.g. because it was stopped for good.
+func (b *RestartBackoff) Forget(id uuid.UUID) {
+	delete(b.restarts, id)
+}
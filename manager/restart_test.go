@@ -0,0 +1,42 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRestartBackoff(t *testing.T) {
+	b := RestartBackoff{InitialBackoff: time.Second, MaxBackoff: 4 * time.Second}
+	id := uuid.New()
+	now := time.Now()
+
+	// the first restart is immediate, then each one waits twice as long as the previous, up to MaxBackoff.
+	if ok, _ := b.Allow(id, 0, now); !ok {
+		t.Fatal("Allow() = false for the first restart")
+	}
+	for _, wait := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		now = now.Add(wait)
+		if ok, retryAt := b.Allow(id, 0, now.Add(-time.Nanosecond)); ok || !retryAt.Equal(now) {
+			t.Fatalf("Allow() = %v, %v just before the %v backoff expired, want false, %v", ok, retryAt, wait, now)
+		}
+		if ok, _ := b.Allow(id, 0, now); !ok {
+			t.Fatalf("Allow() = false once the %v backoff expired", wait)
+		}
+	}
+
+	// a task that ran for MaxBackoff starts over from InitialBackoff.
+	now = now.Add(4 * time.Second)
+	if ok, _ := b.Allow(id, 4*time.Second, now); !ok {
+		t.Fatal("Allow() = false once the backoff expired")
+	}
+	if ok, _ := b.Allow(id, 0, now.Add(time.Second)); !ok {
+		t.Error("Allow() = false after InitialBackoff for a task that had run for MaxBackoff")
+	}
+
+	b.Forget(id)
+	if ok, _ := b.Allow(id, 0, now.Add(time.Second)); !ok {
+		t.Error("Allow() = false for a forgotten task")
+	}
+}
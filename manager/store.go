@@ -0,0 +1,289 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"orchestra/node"
+	"orchestra/task"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Store persists the state the manager needs to carry over a restart: its tasks, their
+// event history, which worker each task was placed on and the workers it knows about.
+// The manager works on its in-memory maps and writes every change through to the Store.
+type Store interface {
+	PutTask(t task.Task) error
+	ListTasks() ([]task.Task, error)
+
+	AppendEvent(te task.TaskEvent) error
+	ListEvents() (map[uuid.UUID][]task.TaskEvent, error)
+
+	PutAssignment(taskID uuid.UUID, worker string) error
+	DeleteAssignment(taskID uuid.UUID) error
+	ListAssignments() (map[uuid.UUID]string, error)
+
+	PutNode(n node.Node) error
+	ListNodes() ([]node.Node, error)
+
+	Close() error
+}
+
+// MemoryStore keeps the manager's state in memory; it's lost when the manager exits.
+type MemoryStore struct {
+	mu          sync.Mutex
+	tasks       map[uuid.UUID]task.Task
+	events      map[uuid.UUID][]task.TaskEvent
+	assignments map[uuid.UUID]string
+	nodes       map[string]node.Node
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tasks:       make(map[uuid.UUID]task.Task),
+		events:      make(map[uuid.UUID][]task.TaskEvent),
+		assignments: make(map[uuid.UUID]string),
+		nodes:       make(map[string]node.Node),
+	}
+}
+
+func (s *MemoryStore) PutTask(t task.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks[t.ID] = t
+	return nil
+}
+
+func (s *MemoryStore) ListTasks() ([]task.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tasks := make([]task.Task, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+func (s *MemoryStore) AppendEvent(te task.TaskEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events[te.Task.ID] = append(s.events[te.Task.ID], te)
+	return nil
+}
+
+func (s *MemoryStore) ListEvents() (map[uuid.UUID][]task.TaskEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := make(map[uuid.UUID][]task.TaskEvent, len(s.events))
+	for id, history := range s.events {
+		events[id] = append([]task.TaskEvent{}, history...)
+	}
+	return events, nil
+}
+
+func (s *MemoryStore) PutAssignment(taskID uuid.UUID, worker string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.assignments[taskID] = worker
+	return nil
+}
+
+func (s *MemoryStore) DeleteAssignment(taskID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.assignments, taskID)
+	return nil
+}
+
+func (s *MemoryStore) ListAssignments() (map[uuid.UUID]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	assignments := make(map[uuid.UUID]string, len(s.assignments))
+	for id, w := range s.assignments {
+		assignments[id] = w
+	}
+	return assignments, nil
+}
+
+func (s *MemoryStore) PutNode(n node.Node) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nodes[n.Name] = n
+	return nil
+}
+
+func (s *MemoryStore) ListNodes() ([]node.Node, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nodes := make([]node.Node, 0, len(s.nodes))
+	for _, n := range s.nodes {
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+var (
+	tasksBucket       = []byte("tasks")
+	eventsBucket      = []byte("events")
+	assignmentsBucket = []byte("assignments")
+	nodesBucket       = []byte("nodes")
+)
+
+// BoltStore keeps the manager's state in an embedded bbolt database file.
+// Every value is stored as JSON; events are kept in a nested bucket per task, in the order they were appended.
+type BoltStore struct {
+	Db *bolt.DB
+}
+
+// NewBoltStore opens, or creates, the database file at path.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{tasksBucket, eventsBucket, assignmentsBucket, nodesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return fmt.Errorf("unable to create bucket %s: %w", b, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{Db: db}, nil
+}
+
+func (s *BoltStore) PutTask(t task.Task) error {
+	return s.put(tasksBucket, []byte(t.ID.String()), t)
+}
+
+func (s *BoltStore) ListTasks() ([]task.Task, error) {
+	var tasks []task.Task
+	err := s.Db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(tasksBucket).ForEach(func(k, v []byte) error {
+			var t task.Task
+			if err := json.Unmarshal(v, &t); err != nil {
+				return fmt.Errorf("unable to unmarshal task %s: %w", k, err)
+			}
+			tasks = append(tasks, t)
+			return nil
+		})
+	})
+	return tasks, err
+}
+
+func (s *BoltStore) AppendEvent(te task.TaskEvent) error {
+	data, err := json.Marshal(te)
+	if err != nil {
+		return fmt.Errorf("unable to marshal event %v: %w", te.ID, err)
+	}
+
+	return s.Db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(eventsBucket).CreateBucketIfNotExists([]byte(te.Task.ID.String()))
+		if err != nil {
+			return err
+		}
+
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(fmt.Sprintf("%020d", seq)), data)
+	})
+}
+
+func (s *BoltStore) ListEvents() (map[uuid.UUID][]task.TaskEvent, error) {
+	events := make(map[uuid.UUID][]task.TaskEvent)
+	err := s.Db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(eventsBucket).ForEachBucket(func(k []byte) error {
+			id, err := uuid.ParseBytes(k)
+			if err != nil {
+				return fmt.Errorf("invalid task ID %s in events: %w", k, err)
+			}
+
+			return tx.Bucket(eventsBucket).Bucket(k).ForEach(func(_, v []byte) error {
+				var te task.TaskEvent
+				if err := json.Unmarshal(v, &te); err != nil {
+					return fmt.Errorf("unable to unmarshal event of task %s: %w", k, err)
+				}
+				events[id] = append(events[id], te)
+				return nil
+			})
+		})
+	})
+	return events, err
+}
+
+func (s *BoltStore) PutAssignment(taskID uuid.UUID, worker string) error {
+	return s.Db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(assignmentsBucket).Put([]byte(taskID.String()), []byte(worker))
+	})
+}
+
+func (s *BoltStore) DeleteAssignment(taskID uuid.UUID) error {
+	return s.Db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(assignmentsBucket).Delete([]byte(taskID.String()))
+	})
+}
+
+func (s *BoltStore) ListAssignments() (map[uuid.UUID]string, error) {
+	assignments := make(map[uuid.UUID]string)
+	err := s.Db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(assignmentsBucket).ForEach(func(k, v []byte) error {
+			id, err := uuid.ParseBytes(k)
+			if err != nil {
+				return fmt.Errorf("invalid task ID %s in assignments: %w", k, err)
+			}
+			assignments[id] = string(v)
+			return nil
+		})
+	})
+	return assignments, err
+}
+
+func (s *BoltStore) PutNode(n node.Node) error {
+	return s.put(nodesBucket, []byte(n.Name), n)
+}
+
+func (s *BoltStore) ListNodes() ([]node.Node, error) {
+	var nodes []node.Node
+	err := s.Db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(nodesBucket).ForEach(func(k, v []byte) error {
+			var n node.Node
+			if err := json.Unmarshal(v, &n); err != nil {
+				return fmt.Errorf("unable to unmarshal node %s: %w", k, err)
+			}
+			nodes = append(nodes, n)
+			return nil
+		})
+	})
+	return nodes, err
+}
+
+func (s *BoltStore) Close() error {
+	return s.Db.Close()
+}
+
+func (s *BoltStore) put(bucket, key []byte, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("unable to marshal %s: %w", key, err)
+	}
+
+	return s.Db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put(key, data)
+	})
+}
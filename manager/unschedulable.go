@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"orchestra/task"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultInitialBackoff is how long a task that couldn't be placed waits before it's tried again the first time.
+	DefaultInitialBackoff = 10 * time.Second
+
+	// DefaultMaxBackoff caps how long a task that keeps failing to be placed waits between tries.
+	DefaultMaxBackoff = 5 * time.Minute
+)
+
+// UnschedulableQueue holds the task events that no worker could take, each until its backoff expires. The wait
+// doubles every time the task fails to be placed again, from InitialBackoff up to MaxBackoff, and is reset once
+// the task is placed. The zero value is an empty queue backing off by the default durations.
+type UnschedulableQueue struct {
+	InitialBackoff time.Duration // InitialBackoff is the wait after the first failure to place a task.
+	MaxBackoff     time.Duration // MaxBackoff caps the wait.
+
+	items    map[uuid.UUID]unschedulableItem // items are keyed by task ID.
+	attempts map[uuid.UUID]int               // attempts counts the failures to place each task since it was last placed.
+}
+
+type unschedulableItem struct {
+	te      task.TaskEvent
+	retryAt time.Time
+}
+
+// Add puts a task event in the queue until its backoff, which grows with every failure to place the task, expires.
+// It returns the backoff.
+func (q *UnschedulableQueue) Add(te task.TaskEvent, now time.Time) time.Duration {
+	if q.items == nil {
+		q.items = make(map[uuid.UUID]unschedulableItem)
+		q.attempts = make(map[uuid.UUID]int)
+	}
+
+	backoff, limit := q.InitialBackoff, q.MaxBackoff
+	if backoff <= 0 {
+		backoff = DefaultInitialBackoff
+	}
+	if limit <= 0 {
+		limit = DefaultMaxBackoff
+	}
+	for i := 0; i < q.attempts[te.Task.ID] && backoff < limit; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, limit)
+
+	q.attempts[te.Task.ID]++
+	q.items[te.Task.ID] = unschedulableItem{te: te, retryAt: now.Add(backoff)}
+	return backoff
+}
+
+// Due removes the task events whose backoff expired by now from the queue and returns them, oldest first.
+func (q *UnschedulableQueue) Due(now time.Time) []task.TaskEvent {
+	var due []task.TaskEvent
+	for id, item := range q.items {
+		if !item.retryAt.After(now) {
+			due = append(due, item.te)
+			delete(q.items, id)
+		}
+	}
+	sortByTime(due)
+	return due
+}
+
+// Flush removes every task event from the queue, whatever its backoff, and returns them oldest first.
+func (q *UnschedulableQueue) Flush() []task.TaskEvent {
+	all := make([]task.TaskEvent, 0, len(q.items))
+	for id, item := range q.items {
+		all = append(all, item.te)
+		delete(q.items, id)
+	}
+	sortByTime(all)
+	return all
+}
+
+// Forget removes the task from the queue and resets its backoff, e.g. once it's placed or stopped.
+func (q *UnschedulableQueue) Forget(id uuid.UUID) {
+	delete(q.items, id)
+	delete(q.attempts, id)
+}
+
+// Len returns the number of task events in the queue.
+func (q *UnschedulableQueue) Len() int {
+	return len(q.items)
+}
+
+func sortByTime(events []task.TaskEvent) {
+	sort.Slice(events, func(i, j int) bool { return events[i].TimeStamp.Before(events[j].TimeStamp) })
+}
@@ -0,0 +1,221 @@
+package manager
+
+import (
+	"orchestra/node"
+	"orchestra/task"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestUnschedulableQueueBackoff(t *testing.T) {
+	q := UnschedulableQueue{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	now := time.Now()
+	te := task.TaskEvent{ID: uuid.New(), TimeStamp: now, Task: task.Task{ID: uuid.New()}}
+
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if got := q.Add(te, now); got != want {
+			t.Errorf("backoff after %d failures = %v, want %v", i+1, got, want)
+		}
+	}
+
+	if due := q.Due(now.Add(4 * time.Second)); len(due) != 0 {
+		t.Errorf("Due() before the backoff expired = %d events, want none", len(due))
+	}
+	if due := q.Due(now.Add(5 * time.Second)); len(due) != 1 || q.Len() != 0 {
+		t.Errorf("Due() once the backoff expired = %d events with %d left, want the event taken off the queue", len(due), q.Len())
+	}
+
+	// Forget resets the backoff of a task that was placed.
+	q.Add(te, now)
+	q.Forget(te.Task.ID)
+	if q.Len() != 0 {
+		t.Errorf("Len() after Forget = %d, want 0", q.Len())
+	}
+	if got := q.Add(te, now); got != time.Second {
+		t.Errorf("backoff after Forget = %v, want it reset to %v", got, time.Second)
+	}
+}
+
+func TestUnschedulableQueueDefaults(t *testing.T) {
+	var q UnschedulableQueue
+	te := task.TaskEvent{ID: uuid.New(), Task: task.Task{ID: uuid.New()}}
+	if got := q.Add(te, time.Now()); got != DefaultInitialBackoff {
+		t.Errorf("first backoff = %v, want %v", got, DefaultInitialBackoff)
+	}
+	for i := 0; i < 10; i++ {
+		q.Add(te, time.Now())
+	}
+	if got := q.Add(te, time.Now()); got != DefaultMaxBackoff {
+		t.Errorf("backoff after many failures = %v, want %v", got, DefaultMaxBackoff)
+	}
+}
+
+func TestUnschedulableQueueFlush(t *testing.T) {
+	var q UnschedulableQueue
+	now := time.Now()
+	var want []uuid.UUID
+	for i := 0; i < 3; i++ {
+		te := task.TaskEvent{ID: uuid.New(), TimeStamp: now.Add(time.Duration(i) * time.Second), Task: task.Task{ID: uuid.New()}}
+		want = append(want, te.Task.ID)
+		q.Add(te, now)
+	}
+
+	got := q.Flush()
+	if len(got) != len(want) || q.Len() != 0 {
+		t.Fatalf("Flush() = %d events with %d left, want %d and none left", len(got), q.Len(), len(want))
+	}
+	for i, te := range got {
+		if te.Task.ID != want[i] {
+			t.Errorf("event %d of Flush() is task %v, want %v, oldest first", i, te.Task.ID, want[i])
+		}
+	}
+}
+
+// setAside submits a task the manager can't place yet, and checks it's moved to the Unschedulable queue.
+func setAside(tb testing.TB, m *Manager, t task.Task) {
+	tb.Helper()
+	if err := m.AddTask(task.TaskEvent{ID: uuid.New(), State: task.Scheduled, TimeStamp: time.Now(), Task: t}); err != nil {
+		tb.Fatal(err)
+	}
+	m.SendWork()
+	if _, placed := m.TaskWorkerMap[t.ID]; placed || m.Unschedulable.Len() != 1 || m.Pending.Len() != 0 {
+		tb.Fatalf("task placed = %v with %d unschedulable and %d pending, want it set aside",
+			placed, m.Unschedulable.Len(), m.Pending.Len())
+	}
+}
+
+// TestRetryUnschedulable checks that the unschedulable tasks are queued again without waiting for their
+// backoff when a worker joins, tasks finish or taints change, and that they're then placed.
+func TestRetryUnschedulable(t *testing.T) {
+	t.Run("worker joins", func(t *testing.T) {
+		m := newTestManager(t)
+		_, _, api := newTestWorker(t, "w1")
+		if err := m.RegisterWorker(nodeFor("w1", api)); err != nil {
+			t.Fatal(err)
+		}
+		big := task.Task{ID: uuid.New(), Image: "nginx:1", Memory: 16 << 30}
+		setAside(t, m, big)
+
+		_, _, api2 := newTestWorker(t, "w2")
+		large := nodeFor("w2", api2)
+		large.Memory = 32 << 30
+		if err := m.RegisterWorker(large); err != nil {
+			t.Fatal(err)
+		}
+		if m.Unschedulable.Len() != 0 || m.Pending.Len() != 1 {
+			t.Fatalf("%d unschedulable and %d pending after a worker joined, want the task queued again", m.Unschedulable.Len(), m.Pending.Len())
+		}
+		m.SendWork()
+		if got := m.TaskWorkerMap[big.ID]; got != "w2" {
+			t.Errorf("task placed on %q, want w2", got)
+		}
+		if got, _, _ := m.GetTask(big.ID); got.PendingReason != "" {
+			t.Errorf("PendingReason of the placed task = %q, want it cleared", got.PendingReason)
+		}
+	})
+
+	t.Run("tasks finish", func(t *testing.T) {
+		m := newTestManager(t)
+		w, rt, api := newTestWorker(t, "w1")
+		if err := m.RegisterWorker(nodeFor("w1", api)); err != nil {
+			t.Fatal(err)
+		}
+		running := runOn(t, m, w, task.Task{ID: uuid.New(), Image: "nginx:1", Memory: 6 << 30})
+		waiting := task.Task{ID: uuid.New(), Image: "nginx:1", Memory: 4 << 30}
+		setAside(t, m, waiting)
+
+		// a worker reporting a task that still runs changes nothing.
+		m.UpdateTasks()
+		if m.Unschedulable.Len() != 1 {
+			t.Fatalf("%d unschedulable after an update with nothing finished, want the task left to its backoff", m.Unschedulable.Len())
+		}
+
+		if err := rt.Exit(running.Runtime.ContainerId, 0); err != nil {
+			t.Fatal(err)
+		}
+		w.UpdateTasks()
+		m.UpdateTasks()
+		if m.Unschedulable.Len() != 0 || m.Pending.Len() != 1 {
+			t.Fatalf("%d unschedulable and %d pending after a task finished, want the task queued again", m.Unschedulable.Len(), m.Pending.Len())
+		}
+		m.SendWork()
+		if got := m.TaskWorkerMap[waiting.ID]; got != "w1" {
+			t.Errorf("task placed on %q, want w1", got)
+		}
+	})
+
+	t.Run("taints change", func(t *testing.T) {
+		m := newTestManager(t)
+		_, _, api := newTestWorker(t, "w1")
+		if err := m.RegisterWorker(nodeFor("w1", api)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.SetTaints("w1", []node.Taint{{Key: "dedicated", Value: "infra", Effect: node.NoSchedule}}); err != nil {
+			t.Fatal(err)
+		}
+		waiting := task.Task{ID: uuid.New(), Image: "nginx:1"}
+		setAside(t, m, waiting)
+
+		if _, err := m.SetTaints("w1", nil); err != nil {
+			t.Fatal(err)
+		}
+		if m.Unschedulable.Len() != 0 || m.Pending.Len() != 1 {
+			t.Fatalf("%d unschedulable and %d pending after the taints changed, want the task queued again", m.Unschedulable.Len(), m.Pending.Len())
+		}
+		m.SendWork()
+		if got := m.TaskWorkerMap[waiting.ID]; got != "w1" {
+			t.Errorf("task placed on %q, want w1", got)
+		}
+	})
+}
+
+func TestRetryUnschedulableWaitsForBackoff(t *testing.T) {
+	m := newTestManager(t)
+	m.Unschedulable.InitialBackoff = time.Hour
+	_, _, api := newTestWorker(t, "w1")
+	if err := m.RegisterWorker(nodeFor("w1", api)); err != nil {
+		t.Fatal(err)
+	}
+	setAside(t, m, task.Task{ID: uuid.New(), Image: "nginx:1", Memory: 16 << 30})
+
+	m.RetryUnschedulable()
+	if m.Unschedulable.Len() != 1 || m.Pending.Len() != 0 {
+		t.Errorf("%d unschedulable and %d pending before the backoff expired, want the task left aside", m.Unschedulable.Len(), m.Pending.Len())
+	}
+}
+
+func TestPendingReason(t *testing.T) {
+	m := newTestManager(t)
+	for _, name := range []string{"w1", "w2", "w3"} {
+		_, _, api := newTestWorker(t, name)
+		n := nodeFor(name, api)
+		if name == "w3" {
+			// w3 has room for the task but is tainted.
+			n.Memory = 32 << 30
+		}
+		if err := m.RegisterWorker(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.SetTaints("w3", []node.Taint{{Key: "dedicated", Value: "infra", Effect: node.NoSchedule}}); err != nil {
+		t.Fatal(err)
+	}
+
+	big := task.Task{ID: uuid.New(), Image: "nginx:1", Memory: 16 << 30}
+	setAside(t, m, big)
+
+	want := "0/3 nodes available: 2 insufficient memory, 1 taint"
+	got, _, ok := m.GetTask(big.ID)
+	if !ok {
+		t.Fatal("task not found")
+	}
+	if got.PendingReason != want {
+		t.Errorf("PendingReason = %q, want %q", got.PendingReason, want)
+	}
+	listed := m.GetTasks()
+	if len(listed) != 1 || listed[0].PendingReason != want {
+		t.Errorf("GetTasks() = %+v, want the task listed with PendingReason %q", listed, want)
+	}
+}
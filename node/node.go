@@ -1,13 +1,131 @@
 package node
 
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Status describes how the manager currently sees a worker node.
+type Status string
+
+const (
+	// Ready indicates that the node is sending heartbeats and can be given tasks.
+	Ready Status = "Ready"
+
+	// NotReady indicates that the node missed its recent heartbeats; it gets no new tasks until it's heard from again.
+	NotReady Status = "NotReady"
+
+	// Gone indicates that the node has been silent for long enough to be considered lost.
+	Gone Status = "Gone"
+)
+
+// Node represents a machine in the cluster along with its capacity and the manager's view of its health.
 type Node struct {
 	Name            string
 	IpAddr          string
-	Cores           int
-	Memory          int
-	MemoryAllocated int
-	Disk            int
-	DiskAllocated   int
-	Role            string
-	TaskCount       int
+	Api             string            // Api is the "host:port" address of the worker's REST API.
+	Cores           int               // Cores is the number of CPU cores on the node.
+	Memory          int               // Memory is the total memory of the node in bytes.
+	MemoryAllocated int               // MemoryAllocated is the memory in bytes reserved by tasks on the node.
+	Disk            int               // Disk is the total disk space of the node in bytes.
+	DiskAllocated   int               // DiskAllocated is the disk space in bytes reserved by tasks on the node.
+	CPUAllocated    float64           // CPUAllocated is the number of cores reserved by tasks on the node.
+	Reserved        Resources         // Reserved is what the node keeps for the system and its own daemons, out of the reach of tasks.
+	Overcommit      Overcommit        // Overcommit is how far the CPU and memory left after Reserved may be promised to tasks.
+	MemoryAvailable int               // MemoryAvailable is the memory in bytes the node last reported as available.
+	Load            float64           // Load is the node's load average over the last minute, as it last reported it.
+	Labels          map[string]string // Labels describe the node, e.g. "disk=ssd" or "rack=r1", for tasks to be placed by.
+	Taints          []Taint           // Taints reserve the node for the tasks that tolerate them.
+	Role            string            // Role is the part the node plays in the cluster, e.g. "worker".
+	TaskCount       int               // TaskCount is the number of tasks the node is currently handling.
+	Status          Status            // Status is the manager's view of the node's health.
+	LastHeartbeat   time.Time         // LastHeartbeat is the time at which the manager last heard from the node.
+}
+
+// Resources is an amount of CPU, memory and disk.
+type Resources struct {
+	CPU    float64 // CPU is a number of cores.
+	Memory int     // Memory is in bytes.
+	Disk   int     // Disk is in bytes.
+}
+
+// Overcommit holds the ratios by which the allocatable CPU and memory of a node exceed what it has,
+// e.g. 2 to promise tasks twice the cores. A ratio of 0 is taken as 1, i.e. no overcommit.
+type Overcommit struct {
+	CPU    float64
+	Memory float64
+}
+
+// AllocatableCPU is the number of cores that may be allocated to tasks: the node's cores less
+// the reserved ones, times the CPU overcommit ratio.
+func (n *Node) AllocatableCPU() float64 {
+	return math.Max(float64(n.Cores)-n.Reserved.CPU, 0) * ratio(n.Overcommit.CPU)
+}
+
+// AllocatableMemory is the memory in bytes that may be allocated to tasks: the node's memory less
+// the reserved memory, times the memory overcommit ratio.
+func (n *Node) AllocatableMemory() int {
+	return int(float64(max(n.Memory-n.Reserved.Memory, 0)) * ratio(n.Overcommit.Memory))
+}
+
+// AllocatableDisk is the disk space in bytes that may be allocated to tasks: the node's disk less the
+// reserved disk. Disk is never overcommitted.
+func (n *Node) AllocatableDisk() int {
+	return max(n.Disk-n.Reserved.Disk, 0)
+}
+
+func ratio(r float64) float64 {
+	if r <= 0 {
+		return 1
+	}
+	return r
+}
+
+// NewNode creates a worker node reachable through the given API address.
+func NewNode(name, api, role string) *Node {
+	return &Node{
+		Name: name,
+		Api:  api,
+		Role: role,
+	}
+}
+
+// TaintEffect is what a Taint does to the tasks that don't tolerate it.
+type TaintEffect string
+
+const (
+	// NoSchedule keeps new tasks that don't tolerate the taint off the node.
+	NoSchedule TaintEffect = "NoSchedule"
+
+	// PreferNoSchedule makes the node a last resort for new tasks that don't tolerate the taint.
+	PreferNoSchedule TaintEffect = "PreferNoSchedule"
+
+	// NoExecute keeps new tasks that don't tolerate the taint off the node and evicts those already on it.
+	NoExecute TaintEffect = "NoExecute"
+)
+
+// Taint marks a node as reserved, e.g. "dedicated=infra", so that only the tasks tolerating it are placed there.
+type Taint struct {
+	Key    string
+	Value  string
+	Effect TaintEffect
+}
+
+// Validate reports whether the taint is well formed.
+func (t Taint) Validate() error {
+	if t.Key == "" {
+		return errors.New("taint has no key")
+	}
+	switch t.Effect {
+	case NoSchedule, PreferNoSchedule, NoExecute:
+		return nil
+	default:
+		return fmt.Errorf("taint %q: unknown effect %q", t.Key, t.Effect)
+	}
+}
+
+func (t Taint) String() string {
+	return fmt.Sprintf("%s=%s:%s", t.Key, t.Value, t.Effect)
 }
@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"orchestra/node"
+	"orchestra/task"
+)
+
+// BinPack places tasks on the most allocated nodes that can still fit them, so that as few nodes
+// as possible are used and the others stay free for large tasks or can be drained.
+type BinPack struct{}
+
+// Spread places tasks on the least allocated nodes, so that load is shared and losing a node
+// affects as few tasks as possible.
+type Spread struct{}
+
+func (b *BinPack) Name() string {
+	return "binpack"
+}
+
+// SelectCandidateNodes keeps the nodes with enough allocatable CPU, memory and disk left for the task
+// whose labels meet its constraints and whose taints it tolerates.
+func (b *BinPack) SelectCandidateNodes(t task.Task, nodes []*node.Node) []*node.Node {
+	return filter(t, nodes, b.filters()...)
+}
+
+func (b *BinPack) filters() []filterPlugin {
+	return []filterPlugin{cpuFilter{}, memoryFilter{}, diskFilter{}, constraintsFilter{}, taintsFilter{}}
+}
+
+func (b *BinPack) scorers() []scorePlugin {
+	return []scorePlugin{affinityScore{}, taintScore{}}
+}
+
+// Score gives every node the share of its memory, disk and CPU that would be allocated once the task is placed on it.
+func (b *BinPack) Score(t task.Task, nodes []*node.Node) map[string]float64 {
+	return addScores(t, nodes, b.strategyScore(t, nodes), b.scorers()...)
+}
+
+func (b *BinPack) strategyScore(t task.Task, nodes []*node.Node) map[string]float64 {
+	scores := make(map[string]float64, len(nodes))
+	for _, n := range nodes {
+		scores[n.Name] = allocatedShare(t, n)
+	}
+	return scores
+}
+
+// Pick returns the most allocated node.
+func (b *BinPack) Pick(scores map[string]float64, candidates []*node.Node) *node.Node {
+	return pickHighest(scores, candidates)
+}
+
+func (s *Spread) Name() string {
+	return "spread"
+}
+
+// SelectCandidateNodes keeps the nodes with enough allocatable CPU, memory and disk left for the task
+// whose labels meet its constraints and whose taints it tolerates.
+func (s *Spread) SelectCandidateNodes(t task.Task, nodes []*node.Node) []*node.Node {
+	return filter(t, nodes, s.filters()...)
+}
+
+func (s *Spread) filters() []filterPlugin {
+	return []filterPlugin{cpuFilter{}, memoryFilter{}, diskFilter{}, constraintsFilter{}, taintsFilter{}}
+}
+
+func (s *Spread) scorers() []scorePlugin {
+	return []scorePlugin{affinityScore{}, taintScore{}}
+}
+
+// Score gives every node the share of its memory, disk and CPU that would still be free once the task is placed on it.
+func (s *Spread) Score(t task.Task, nodes []*node.Node) map[string]float64 {
+	return addScores(t, nodes, s.strategyScore(t, nodes), s.scorers()...)
+}
+
+func (s *Spread) strategyScore(t task.Task, nodes []*node.Node) map[string]float64 {
+	scores := make(map[string]float64, len(nodes))
+	for _, n := range nodes {
+		scores[n.Name] = 1 - allocatedShare(t, n)
+	}
+	return scores
+}
+
+// Pick returns the least allocated node.
+func (s *Spread) Pick(scores map[string]float64, candidates []*node.Node) *node.Node {
+	return pickHighest(scores, candidates)
+}
+
+// allocatedShare is the average, over the allocatable memory, disk and CPU of the node, of the share that
+// would be allocated once the task is placed on it. Resources the node has none of to allocate are left out.
+func allocatedShare(t task.Task, n *node.Node) float64 {
+	var shares []float64
+	if memory := n.AllocatableMemory(); memory > 0 {
+		shares = append(shares, float64(n.MemoryAllocated+t.Memory)/float64(memory))
+	}
+	if disk := n.AllocatableDisk(); disk > 0 {
+		shares = append(shares, float64(n.DiskAllocated+t.Disk*Gigabyte)/float64(disk))
+	}
+	if cpu := n.AllocatableCPU(); cpu > 0 {
+		shares = append(shares, (n.CPUAllocated+t.CPU)/cpu)
+	}
+	if len(shares) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, s := range shares {
+		sum += s
+	}
+	return sum / float64(len(shares))
+}
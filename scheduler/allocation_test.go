@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"math"
+	"orchestra/node"
+	"orchestra/task"
+	"testing"
+)
+
+func TestAllocatedShare(t *testing.T) {
+	tests := []struct {
+		name string
+		node *node.Node
+		task task.Task
+		want float64
+	}{
+		{"empty node", newNode("a", 4, 8<<30), task.Task{}, 0},
+		{"half of everything", newNode("a", 4, 8<<30), task.Task{CPU: 2, Memory: 4 << 30, Disk: 50}, 0.5},
+		{"with what's allocated", &node.Node{Cores: 4, Memory: 8 << 30, Disk: 100 * Gigabyte, CPUAllocated: 1, MemoryAllocated: 2 << 30, DiskAllocated: 25 * Gigabyte}, task.Task{CPU: 1, Memory: 2 << 30, Disk: 25}, 0.5},
+		{"no disk to allocate", &node.Node{Cores: 4, Memory: 8 << 30}, task.Task{CPU: 1, Memory: 6 << 30}, 0.5},
+		{"nothing to allocate", &node.Node{}, task.Task{CPU: 1}, 0},
+	}
+	for _, tt := range tests {
+		if got := allocatedShare(tt.task, tt.node); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: allocatedShare() = %g, want %g", tt.name, got, tt.want)
+		}
+	}
+}
+
+// allocatedNodes returns nodes with none, half and three quarters of their memory allocated, and one with
+// all of it, out of order.
+func allocatedNodes() []*node.Node {
+	half, empty, most, full := newNode("half", 4, 8<<30), newNode("empty", 4, 8<<30), newNode("most", 4, 8<<30), newNode("full", 4, 8<<30)
+	half.MemoryAllocated = 4 << 30
+	most.MemoryAllocated = 6 << 30
+	full.MemoryAllocated = 8 << 30
+	return []*node.Node{half, empty, most, full}
+}
+
+func TestBinPackPicksMostAllocated(t *testing.T) {
+	if got := place(&BinPack{}, task.Task{Memory: 1 << 30}, allocatedNodes()); got != "most" {
+		t.Errorf("task placed on %q, want most, the most allocated node it fits on", got)
+	}
+	if got := place(&BinPack{}, task.Task{Memory: 3 << 30}, allocatedNodes()); got != "half" {
+		t.Errorf("task placed on %q, want half, the most allocated node it fits on", got)
+	}
+}
+
+func TestSpreadPicksLeastAllocated(t *testing.T) {
+	if got := place(&Spread{}, task.Task{Memory: 1 << 30}, allocatedNodes()); got != "empty" {
+		t.Errorf("task placed on %q, want empty, the least allocated node", got)
+	}
+
+}
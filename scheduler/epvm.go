@@ -0,0 +1,80 @@
+package scheduler
+
+import (
+	"math"
+	"orchestra/node"
+	"orchestra/task"
+)
+
+// lieb is the base of the E-PVM cost of using a resource: the cost of a resource
+// used at a fraction u of its capacity is lieb^u.
+const lieb = 1.53960071783900203869
+
+// defaultTaskCPU is the CPU, in cores, a task that doesn't ask for any is expected to use.
+const defaultTaskCPU = 0.1
+
+// Epvm places tasks using the Enhanced PVM cost model: every node is given the marginal cost of adding the
+// task's CPU and memory to what the node already uses, and the task goes where that cost is lowest. Costs
+// grow exponentially with utilisation, so busy nodes are avoided well before they're full.
+type Epvm struct{}
+
+func (e *Epvm) Name() string {
+	return "epvm"
+}
+
+// SelectCandidateNodes keeps the nodes with enough allocatable CPU, memory and disk left for the task
+// whose labels meet its constraints and whose taints it tolerates.
+func (e *Epvm) SelectCandidateNodes(t task.Task, nodes []*node.Node) []*node.Node {
+	return filter(t, nodes, e.filters()...)
+}
+
+func (e *Epvm) filters() []filterPlugin {
+	return []filterPlugin{cpuFilter{}, memoryFilter{}, diskFilter{}, constraintsFilter{}, taintsFilter{}}
+}
+
+func (e *Epvm) scorers() []scorePlugin {
+	return []scorePlugin{affinityScore{}, taintScore{}}
+}
+
+// Score gives every node the opposite of its marginal cost, so that the cheapest node scores highest.
+// CPU utilisation comes from the node's load average and memory utilisation from the larger of the memory
+// the node reports in use and the memory allocated to its tasks, out of its allocatable memory.
+func (e *Epvm) Score(t task.Task, nodes []*node.Node) map[string]float64 {
+	return addScores(t, nodes, e.strategyScore(t, nodes), e.scorers()...)
+}
+
+func (e *Epvm) strategyScore(t task.Task, nodes []*node.Node) map[string]float64 {
+	taskCPU := t.CPU
+	if taskCPU <= 0 {
+		taskCPU = defaultTaskCPU
+	}
+
+	scores := make(map[string]float64, len(nodes))
+	for _, n := range nodes {
+		cores := float64(n.Cores)
+		if cores < 1 {
+			cores = 1
+		}
+		cpuLoad := n.Load / cores
+		cost := marginalCost(cpuLoad, cpuLoad+taskCPU/cores)
+
+		if memory := n.AllocatableMemory(); memory > 0 {
+			used := math.Max(float64(n.Memory-n.MemoryAvailable), float64(n.MemoryAllocated))
+			memLoad := used / float64(memory)
+			cost += marginalCost(memLoad, memLoad+float64(t.Memory)/float64(memory))
+		}
+
+		scores[n.Name] = -cost
+	}
+	return scores
+}
+
+// Pick returns the node with the lowest marginal cost.
+func (e *Epvm) Pick(scores map[string]float64, candidates []*node.Node) *node.Node {
+	return pickHighest(scores, candidates)
+}
+
+// marginalCost is how much the E-PVM cost of a resource grows when its utilisation goes from before to after.
+func marginalCost(before, after float64) float64 {
+	return math.Pow(lieb, after) - math.Pow(lieb, before)
+}
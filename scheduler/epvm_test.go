@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"math"
+	"orchestra/node"
+	"orchestra/task"
+	"slices"
+	"testing"
+)
+
+func TestEpvmPicksCheapestNode(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b func(n *node.Node)
+		task task.Task
+		want string
+	}{
+		{
+			name: "lower load",
+			a:    func(n *node.Node) { n.Load = 3 },
+			b:    func(n *node.Node) { n.Load = 0.5 },
+			task: task.Task{CPU: 1},
+			want: "b",
+		},
+		{
+			name: "more cores for the same load",
+			a:    func(n *node.Node) { n.Cores, n.Load = 2, 1 },
+			b:    func(n *node.Node) { n.Cores, n.Load = 8, 2 },
+			task: task.Task{CPU: 1},
+			want: "b",
+		},
+		{
+			name: "less memory in use",
+			a:    func(n *node.Node) { n.MemoryAvailable = 1 << 30 },
+			b:    func(n *node.Node) { n.MemoryAvailable = 6 << 30 },
+			task: task.Task{Memory: 1 << 30},
+			want: "b",
+		},
+		{
+			name: "allocated memory counts when more than what's in use",
+			a:    func(n *node.Node) { n.MemoryAvailable, n.MemoryAllocated = 8<<30, 6<<30 },
+			b:    func(n *node.Node) { n.MemoryAvailable, n.MemoryAllocated = 6<<30, 1<<30 },
+			task: task.Task{Memory: 1 << 30},
+			want: "b",
+		},
+		{
+			name: "idle first when equal",
+			a:    func(n *node.Node) {},
+			b:    func(n *node.Node) {},
+			task: task.Task{},
+			want: "a",
+		},
+	}
+
+	for _, tt := range tests {
+		a, b := newNode("a", 4, 8<<30), newNode("b", 4, 8<<30)
+		a.MemoryAvailable, b.MemoryAvailable = a.Memory, b.Memory
+		tt.a(a)
+		tt.b(b)
+		if got := place(&Epvm{}, tt.task, []*node.Node{a, b}); got != tt.want {
+			t.Errorf("%s: task placed on %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEpvmScore(t *testing.T) {
+	n := newNode("a", 4, 0)
+	scores := (&Epvm{}).Score(task.Task{CPU: 1}, []*node.Node{n})
+	if want := -(math.Pow(lieb, 0.25) - 1); math.Abs(scores["a"]-want) > 1e-9 {
+		t.Errorf("Score() = %g for a quarter of an idle node's cores, want %g", scores["a"], want)
+	}
+
+	// a task that asks for no CPU is expected to use some.
+	scores = (&Epvm{}).Score(task.Task{}, []*node.Node{n})
+	if want := -(math.Pow(lieb, defaultTaskCPU/4) - 1); math.Abs(scores["a"]-want) > 1e-9 {
+		t.Errorf("Score() = %g for a task without CPU, want %g", scores["a"], want)
+	}
+}
+
+// TestEpvmFiltersNodesThatCantFit checks that a node the task doesn't fit on isn't picked, however idle it is.
+func TestEpvmFiltersNodesThatCantFit(t *testing.T) {
+	idle := newNode("idle", 2, 8<<30)
+	busy := newNode("busy", 8, 8<<30)
+	busy.Load = 6
+	full := newNode("full", 8, 8<<30)
+	full.MemoryAllocated = 6 << 30
+	nodes := []*node.Node{idle, busy, full}
+
+	tk := task.Task{CPU: 4, Memory: 4 << 30}
+	if got := names((&Epvm{}).SelectCandidateNodes(tk, nodes)); !slices.Equal(got, []string{"busy"}) {
+		t.Errorf("SelectCandidateNodes() = %v, want [busy]", got)
+	}
+	if got := place(&Epvm{}, tk, nodes); got != "busy" {
+		t.Errorf("task placed on %q, want busy, the only node it fits on", got)
+	}
+	if got := place(&Epvm{}, task.Task{CPU: 16}, nodes); got != "" {
+		t.Errorf("task placed on %q, want no node", got)
+	}
+}
+
+func TestMarginalCost(t *testing.T) {
+	if c := marginalCost(0.5, 0.5); c != 0 {
+		t.Errorf("marginalCost() = %g for no change", c)
+	}
+	if low, high := marginalCost(0.1, 0.2), marginalCost(0.8, 0.9); low >= high {
+		t.Errorf("marginalCost() = %g at 10%% and %g at 80%%, want it to grow with utilisation", low, high)
+	}
+}
@@ -0,0 +1,165 @@
+package scheduler
+
+import (
+	"fmt"
+	"orchestra/node"
+	"orchestra/task"
+	"sort"
+	"strings"
+)
+
+// Explanation tells how a scheduler would place a task: what it made of every node and which one it would pick.
+type Explanation struct {
+	Scheduler string            // Scheduler is the name of the scheduler that was asked.
+	Nodes     []NodeExplanation // Nodes are in the order the nodes were given in.
+	Selected  string            // Selected is the name of the node the task would be placed on, empty if none.
+}
+
+// NodeExplanation is what a scheduler made of one node for a task.
+type NodeExplanation struct {
+	Name      string             // Name is the name of the node.
+	Candidate bool               // Candidate tells whether the node passed the filter step.
+	Filter    string             // Filter is the name of the filter that rejected the node, if one did.
+	Reason    string             // Reason is why the node was rejected, if it was.
+	Scores    map[string]float64 // Scores holds what each score plugin gave a candidate, the strategy's own score being under the scheduler's name.
+	Score     float64            // Score is the sum of Scores, the score Pick chose from.
+}
+
+// pluginScheduler is implemented by the schedulers built from this package's plugins, so that Explain can
+// tell which filter rejects a node and what each score plugin gives it.
+type pluginScheduler interface {
+	Scheduler
+	filters() []filterPlugin
+	scorers() []scorePlugin
+
+	// strategyScore is the scheduler's own score, before the score plugins are added.
+	strategyScore(t task.Task, nodes []*node.Node) map[string]float64
+}
+
+// picker is implemented by the schedulers whose Pick changes their state, so that Explain can tell which node
+// Pick would return without calling it.
+type picker interface {
+	pick(scores map[string]float64, candidates []*node.Node) *node.Node
+}
+
+// summaries are the short reasons the rejections of each filter are counted under by Summary.
+var summaries = map[string]string{
+	"status":      "not ready",
+	"cpu":         "insufficient cpu",
+	"memory":      "insufficient memory",
+	"disk":        "insufficient disk",
+	"constraints": "unmet constraints",
+	"taints":      "taint",
+}
+
+// Explain runs the filter, score and pick steps of the scheduler for the task over the nodes and reports
+// the outcome of each for every node, without placing the task. It leaves the schedulers of this package
+// as they were, so that e.g. a RoundRobin still hands the next task to the same node. The nodes that aren't
+// Ready are rejected by the "status" filter without being handed to the scheduler.
+//
+// Schedulers from elsewhere are run as they are: their rejections come without a reason and their
+// scores aren't broken down, and Explain may change their state as Score and Pick would.
+func Explain(s Scheduler, t task.Task, nodes []*node.Node) Explanation {
+	e := Explanation{Scheduler: s.Name(), Nodes: make([]NodeExplanation, len(nodes))}
+	ps, ok := s.(pluginScheduler)
+
+	var candidates []*node.Node
+	for i, n := range nodes {
+		ne := NodeExplanation{Name: n.Name, Candidate: true}
+		if n.Status != node.Ready {
+			ne.Candidate, ne.Filter, ne.Reason = false, "status", fmt.Sprintf("node is %s", n.Status)
+		} else if ok {
+			for _, p := range ps.filters() {
+				if err := p.filter(t, n); err != nil {
+					ne.Candidate, ne.Filter, ne.Reason = false, p.name(), err.Error()
+					break
+				}
+			}
+		} else if len(s.SelectCandidateNodes(t, []*node.Node{n})) == 0 {
+			ne.Candidate, ne.Filter, ne.Reason = false, s.Name(), "rejected by the scheduler"
+		}
+		if ne.Candidate {
+			candidates = append(candidates, n)
+		}
+		e.Nodes[i] = ne
+	}
+	if len(candidates) == 0 {
+		return e
+	}
+
+	var totals map[string]float64
+	if ok {
+		totals = ps.strategyScore(t, candidates)
+	} else {
+		totals = s.Score(t, candidates)
+	}
+	for i, ne := range e.Nodes {
+		if !ne.Candidate {
+			continue
+		}
+		ne.Scores = map[string]float64{s.Name(): totals[ne.Name]}
+		if ok {
+			for _, p := range ps.scorers() {
+				score := p.score(t, nodes[i])
+				ne.Scores[p.name()] = score
+				totals[ne.Name] += score
+			}
+		}
+		ne.Score = totals[ne.Name]
+		e.Nodes[i] = ne
+	}
+
+	var picked *node.Node
+	if p, ok := s.(picker); ok {
+		picked = p.pick(totals, candidates)
+	} else {
+		picked = s.Pick(totals, candidates)
+	}
+	if picked != nil {
+		e.Selected = picked.Name
+	}
+	return e
+}
+
+// Summary counts the nodes the task could go to and why the others were rejected, e.g.
+// "0/3 nodes available: 2 insufficient memory, 1 taint".
+func (e Explanation) Summary() string {
+	if len(e.Nodes) == 0 {
+		return "0/0 nodes available: no nodes"
+	}
+
+	var candidates int
+	rejected := make(map[string]int)
+	for _, n := range e.Nodes {
+		if n.Candidate {
+			candidates++
+			continue
+		}
+		reason, ok := summaries[n.Filter]
+		if !ok {
+			reason = n.Filter
+		}
+		rejected[reason]++
+	}
+
+	reasons := make([]string, 0, len(rejected))
+	for reason := range rejected {
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if rejected[reasons[i]] != rejected[reasons[j]] {
+			return rejected[reasons[i]] > rejected[reasons[j]]
+		}
+		return reasons[i] < reasons[j]
+	})
+
+	summary := fmt.Sprintf("%d/%d nodes available", candidates, len(e.Nodes))
+	if len(reasons) == 0 {
+		return summary
+	}
+	counts := make([]string, len(reasons))
+	for i, reason := range reasons {
+		counts[i] = fmt.Sprintf("%d %s", rejected[reason], reason)
+	}
+	return summary + ": " + strings.Join(counts, ", ")
+}
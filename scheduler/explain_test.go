@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"orchestra/node"
+	"orchestra/task"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	ready := newNode("ready", 4, 8<<30)
+	ready.Labels = map[string]string{"disk": "ssd"}
+	small := newNode("small", 4, 1<<30)
+	down := newNode("down", 4, 8<<30)
+	down.Status = node.NotReady
+	tainted := newNode("tainted", 4, 8<<30)
+	tainted.Taints = []node.Taint{{Key: "dedicated", Value: "infra", Effect: node.NoExecute}}
+	nodes := []*node.Node{ready, small, down, tainted}
+
+	r := &RoundRobin{LastWorker: len(nodes) - 1}
+	tk := task.Task{Memory: 2 << 30, Affinities: []task.Constraint{{Key: "disk", Operator: task.Exists}}}
+	e := Explain(r, tk, nodes)
+
+	if e.Scheduler != "roundrobin" || e.Selected != "ready" {
+		t.Errorf("Explain() = scheduler %q selected %q, want roundrobin selecting ready", e.Scheduler, e.Selected)
+	}
+	want := []struct {
+		candidate bool
+		filter    string
+	}{
+		{true, ""},
+		{false, "memory"},
+		{false, "status"},
+		{false, "taints"},
+	}
+	for i, w := range want {
+		got := e.Nodes[i]
+		if got.Name != nodes[i].Name || got.Candidate != w.candidate || got.Filter != w.filter {
+			t.Errorf("Explain() node %d = %+v, want %s candidate %v filtered by %q", i, got, nodes[i].Name, w.candidate, w.filter)
+		}
+		if !got.Candidate && got.Reason == "" {
+			t.Errorf("Explain() gives no reason for rejecting %s", got.Name)
+		}
+	}
+	scores := e.Nodes[0].Scores
+	if scores["roundrobin"] != 1 || scores["affinity"] != AffinityWeight || e.Nodes[0].Score != 1+AffinityWeight {
+		t.Errorf("Explain() scores of ready = %v totalling %g", scores, e.Nodes[0].Score)
+	}
+
+	if got := e.Summary(); got != "1/4 nodes available: 1 insufficient memory, 1 not ready, 1 taint" {
+		t.Errorf("Summary() = %q", got)
+	}
+}
+
+// TestExplainLeavesSchedulerAsItWas checks that a dry run doesn't move a RoundRobin on, so the task it
+// explains is then placed where it said.
+func TestExplainLeavesSchedulerAsItWas(t *testing.T) {
+	nodes := []*node.Node{newNode("a", 4, 8<<30), newNode("b", 4, 8<<30)}
+	r := &RoundRobin{}
+
+	for i := 0; i < 3; i++ {
+		e := Explain(r, task.Task{}, nodes)
+		if got := place(r, task.Task{}, nodes); got != e.Selected {
+			t.Errorf("task placed on %q after Explain() selected %q", got, e.Selected)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	tests := []struct {
+		nodes []NodeExplanation
+		want  string
+	}{
+		{nil, "0/0 nodes available: no nodes"},
+		{[]NodeExplanation{{Candidate: true}, {Candidate: true}}, "2/2 nodes available"},
+		{
+			[]NodeExplanation{{Filter: "cpu"}, {Filter: "memory"}, {Filter: "memory"}, {Filter: "custom"}, {Candidate: true}},
+			"1/5 nodes available: 2 insufficient memory, 1 custom, 1 insufficient cpu",
+		},
+	}
+	for _, tt := range tests {
+		if got := (Explanation{Nodes: tt.nodes}).Summary(); got != tt.want {
+			t.Errorf("Summary() = %q, want %q", got, tt.want)
+		}
+	}
+}
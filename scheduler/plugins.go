@@ -0,0 +1,170 @@
+package scheduler
+
+import (
+	"fmt"
+	"orchestra/node"
+	"orchestra/task"
+)
+
+// AffinityWeight is what a node gains for every affinity of a task its labels meet, and loses for every
+// anti-affinity they meet. It's about the range of the strategies' own scores, so that a preference
+// outweighs the strategy but two nodes meeting the same preferences are still told apart by the strategy.
+const AffinityWeight = 1.0
+
+// TaintWeight is what a node loses for every PreferNoSchedule taint the task doesn't tolerate.
+const TaintWeight = 1.0
+
+// filterPlugin is a check shared by the schedulers' filter steps.
+type filterPlugin interface {
+	name() string
+
+	// filter returns why the node can't run the task, or nil if it can.
+	filter(t task.Task, n *node.Node) error
+}
+
+// scorePlugin is a score shared by the schedulers' score steps, added to the strategy's own score.
+type scorePlugin interface {
+	name() string
+	score(t task.Task, n *node.Node) float64
+}
+
+// cpuFilter rejects the nodes without enough allocatable CPU left for the task.
+type cpuFilter struct{}
+
+func (cpuFilter) name() string {
+	return "cpu"
+}
+
+func (cpuFilter) filter(t task.Task, n *node.Node) error {
+	if left := n.AllocatableCPU() - n.CPUAllocated; t.CPU > left {
+		return fmt.Errorf("needs %g cores, %g left", t.CPU, left)
+	}
+	return nil
+}
+
+// memoryFilter rejects the nodes without enough allocatable memory left for the task.
+type memoryFilter struct{}
+
+func (memoryFilter) name() string {
+	return "memory"
+}
+
+func (memoryFilter) filter(t task.Task, n *node.Node) error {
+	if left := n.AllocatableMemory() - n.MemoryAllocated; t.Memory > left {
+		return fmt.Errorf("needs %d bytes of memory, %d left", t.Memory, left)
+	}
+	return nil
+}
+
+// diskFilter rejects the nodes without enough allocatable disk left for the task.
+type diskFilter struct{}
+
+func (diskFilter) name() string {
+	return "disk"
+}
+
+func (diskFilter) filter(t task.Task, n *node.Node) error {
+	if left := n.AllocatableDisk() - n.DiskAllocated; t.Disk*Gigabyte > left {
+		return fmt.Errorf("needs %d bytes of disk, %d left", t.Disk*Gigabyte, left)
+	}
+	return nil
+}
+
+// constraintsFilter rejects the nodes whose labels don't meet every constraint of the task.
+type constraintsFilter struct{}
+
+func (constraintsFilter) name() string {
+	return "constraints"
+}
+
+func (constraintsFilter) filter(t task.Task, n *node.Node) error {
+	for _, c := range t.Constraints {
+		if !c.Matches(n.Labels) {
+			return fmt.Errorf("does not meet constraint %s", c)
+		}
+	}
+	return nil
+}
+
+// taintsFilter rejects the nodes with NoSchedule or NoExecute taints the task doesn't tolerate.
+type taintsFilter struct{}
+
+func (taintsFilter) name() string {
+	return "taints"
+}
+
+func (taintsFilter) filter(t task.Task, n *node.Node) error {
+	for _, taint := range n.Taints {
+		if taint.Effect != node.PreferNoSchedule && !t.Tolerates(taint) {
+			return fmt.Errorf("has taint %s, not tolerated", taint)
+		}
+	}
+	return nil
+}
+
+// taintScore disfavours the nodes with PreferNoSchedule taints the task doesn't tolerate.
+type taintScore struct{}
+
+func (taintScore) name() string {
+	return "taints"
+}
+
+func (taintScore) score(t task.Task, n *node.Node) float64 {
+	var score float64
+	for _, taint := range n.Taints {
+		if taint.Effect == node.PreferNoSchedule && !t.Tolerates(taint) {
+			score -= TaintWeight
+		}
+	}
+	return score
+}
+
+// affinityScore favours the nodes meeting the affinities of the task and disfavours those meeting its anti-affinities.
+type affinityScore struct{}
+
+func (affinityScore) name() string {
+	return "affinity"
+}
+
+func (affinityScore) score(t task.Task, n *node.Node) float64 {
+	var score float64
+	for _, c := range t.Affinities {
+		if c.Matches(n.Labels) {
+			score += AffinityWeight
+		}
+	}
+	for _, c := range t.AntiAffinities {
+		if c.Matches(n.Labels) {
+			score -= AffinityWeight
+		}
+	}
+	return score
+}
+
+// filter returns the nodes that pass every plugin.
+func filter(t task.Task, nodes []*node.Node, plugins ...filterPlugin) []*node.Node {
+	var candidates []*node.Node
+	for _, n := range nodes {
+		passes := true
+		for _, p := range plugins {
+			if p.filter(t, n) != nil {
+				passes = false
+				break
+			}
+		}
+		if passes {
+			candidates = append(candidates, n)
+		}
+	}
+	return candidates
+}
+
+// addScores adds what every plugin gives each node to the scores.
+func addScores(t task.Task, nodes []*node.Node, scores map[string]float64, plugins ...scorePlugin) map[string]float64 {
+	for _, n := range nodes {
+		for _, p := range plugins {
+			scores[n.Name] += p.score(t, n)
+		}
+	}
+	return scores
+}
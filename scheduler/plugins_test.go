@@ -0,0 +1,128 @@
+package scheduler
+
+import (
+	"orchestra/node"
+	"orchestra/task"
+	"testing"
+)
+
+func TestConstraintsFilter(t *testing.T) {
+	n := newNode("a", 4, 8<<30)
+	n.Labels = map[string]string{"disk": "ssd", "rack": "r1"}
+
+	tests := []struct {
+		constraints []task.Constraint
+		pass        bool
+	}{
+		{nil, true},
+		{[]task.Constraint{{Key: "disk", Operator: task.Equals, Values: []string{"ssd"}}}, true},
+		{[]task.Constraint{{Key: "disk", Operator: task.Equals, Values: []string{"ssd"}}, {Key: "rack", Operator: task.In, Values: []string{"r2"}}}, false},
+		{[]task.Constraint{{Key: "gpu", Operator: task.Exists}}, false},
+	}
+	for _, tt := range tests {
+		err := constraintsFilter{}.filter(task.Task{Constraints: tt.constraints}, n)
+		if (err == nil) != tt.pass {
+			t.Errorf("filter() with constraints %v = %v, want pass %v", tt.constraints, err, tt.pass)
+		}
+	}
+}
+
+func TestAffinityScore(t *testing.T) {
+	n := newNode("a", 4, 8<<30)
+	n.Labels = map[string]string{"disk": "ssd", "rack": "r1"}
+	ssd := task.Constraint{Key: "disk", Operator: task.Equals, Values: []string{"ssd"}}
+	r1 := task.Constraint{Key: "rack", Operator: task.In, Values: []string{"r1", "r2"}}
+	gpu := task.Constraint{Key: "gpu", Operator: task.Exists}
+
+	tests := []struct {
+		name             string
+		affinities, anti []task.Constraint
+		want             float64
+	}{
+		{"none", nil, nil, 0},
+		{"one met", []task.Constraint{ssd}, nil, AffinityWeight},
+		{"two met, one not", []task.Constraint{ssd, r1, gpu}, nil, 2 * AffinityWeight},
+		{"anti-affinity met", nil, []task.Constraint{r1}, -AffinityWeight},
+		{"anti-affinity not met", nil, []task.Constraint{gpu}, 0},
+		{"both", []task.Constraint{ssd}, []task.Constraint{r1}, 0},
+	}
+	for _, tt := range tests {
+		tk := task.Task{Affinities: tt.affinities, AntiAffinities: tt.anti}
+		if got := (affinityScore{}).score(tk, n); got != tt.want {
+			t.Errorf("%s: score() = %g, want %g", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestAffinityOutweighsStrategy checks that a preference takes a task to a node the strategy ranks lower,
+// and that nodes meeting the same preferences are still ranked by the strategy.
+func TestAffinityOutweighsStrategy(t *testing.T) {
+	busy, idle, other := newNode("busy", 4, 8<<30), newNode("idle", 4, 8<<30), newNode("other", 4, 8<<30)
+	busy.MemoryAllocated = 6 << 30
+	other.MemoryAllocated = 2 << 30
+	busy.Labels = map[string]string{"disk": "ssd"}
+	other.Labels = map[string]string{"disk": "ssd"}
+	nodes := []*node.Node{busy, idle, other}
+
+	ssd := task.Task{Affinities: []task.Constraint{{Key: "disk", Operator: task.Equals, Values: []string{"ssd"}}}}
+	if got := place(&Spread{}, ssd, nodes); got != "other" {
+		t.Errorf("task preferring ssd placed on %q by spread, want other, the least allocated ssd node", got)
+	}
+	if got := place(&Spread{}, task.Task{}, nodes); got != "idle" {
+		t.Errorf("task without preferences placed on %q by spread, want idle", got)
+	}
+}
+
+func TestTaintsFilter(t *testing.T) {
+	tests := []struct {
+		effect node.TaintEffect
+		tol    []task.Toleration
+		pass   bool
+	}{
+		{node.NoSchedule, nil, false},
+		{node.NoExecute, nil, false},
+		{node.PreferNoSchedule, nil, true}, // left to taintScore.
+		{node.NoSchedule, []task.Toleration{{Key: "dedicated", Operator: task.Equals, Value: "infra"}}, true},
+		{node.NoExecute, []task.Toleration{{Key: "dedicated", Operator: task.Exists, Effect: node.NoSchedule}}, false},
+	}
+	for _, tt := range tests {
+		n := newNode("a", 4, 8<<30)
+		n.Taints = []node.Taint{{Key: "dedicated", Value: "infra", Effect: tt.effect}}
+		err := taintsFilter{}.filter(task.Task{Tolerations: tt.tol}, n)
+		if (err == nil) != tt.pass {
+			t.Errorf("filter() of a %s taint with tolerations %v = %v, want pass %v", tt.effect, tt.tol, err, tt.pass)
+		}
+	}
+}
+
+func TestTaintScore(t *testing.T) {
+	n := newNode("a", 4, 8<<30)
+	n.Taints = []node.Taint{
+		{Key: "spot", Effect: node.PreferNoSchedule},
+		{Key: "old", Effect: node.PreferNoSchedule},
+		{Key: "dedicated", Value: "infra", Effect: node.NoSchedule},
+	}
+
+	if got := (taintScore{}).score(task.Task{}, n); got != -2*TaintWeight {
+		t.Errorf("score() = %g for two untolerated PreferNoSchedule taints, want %g", got, -2*TaintWeight)
+	}
+	spot := task.Task{Tolerations: []task.Toleration{{Key: "spot", Operator: task.Exists}}}
+	if got := (taintScore{}).score(spot, n); got != -TaintWeight {
+		t.Errorf("score() = %g with one of them tolerated, want %g", got, -TaintWeight)
+	}
+}
+
+// TestPreferNoScheduleIsLastResort checks that a node with a PreferNoSchedule taint only gets the tasks
+// that don't tolerate it when no other node can take them.
+func TestPreferNoScheduleIsLastResort(t *testing.T) {
+	spot, small := newNode("spot", 4, 8<<30), newNode("small", 4, 1<<30)
+	spot.Taints = []node.Taint{{Key: "spot", Effect: node.PreferNoSchedule}}
+	nodes := []*node.Node{spot, small}
+
+	if got := place(&BinPack{}, task.Task{Memory: 512 << 20}, nodes); got != "small" {
+		t.Errorf("task placed on %q, want small rather than the tainted node", got)
+	}
+	if got := place(&BinPack{}, task.Task{Memory: 2 << 30}, nodes); got != "spot" {
+		t.Errorf("task placed on %q, want spot, the only node it fits on", got)
+	}
+}
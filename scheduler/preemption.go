@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"orchestra/node"
+	"orchestra/task"
+	"sort"
+)
+
+// SelectVictims looks for the node on which stopping the fewest and least important tasks, all of them
+// of lower priority than t, would let the scheduler place t. running maps node names to the tasks
+// active on them. It returns nil when no such node exists.
+//
+// Victims are taken on each node from the lowest priority up, the most recently started first, until
+// the node becomes a candidate for t. The node whose most important victim has the lowest priority
+// wins, and fewer victims break ties.
+func SelectVictims(s Scheduler, t task.Task, nodes []*node.Node, running map[string][]task.Task) (*node.Node, []task.Task) {
+	var best *node.Node
+	var bestVictims []task.Task
+	for _, n := range nodes {
+		victims, ok := victimsOn(s, t, n, running[n.Name])
+		if !ok {
+			continue
+		}
+		if best == nil || lessDisruptive(victims, bestVictims) {
+			best, bestVictims = n, victims
+		}
+	}
+	return best, bestVictims
+}
+
+// victimsOn returns the tasks to stop on the node for t to become placeable there, if any are enough.
+func victimsOn(s Scheduler, t task.Task, n *node.Node, tasks []task.Task) ([]task.Task, bool) {
+	var preemptible []task.Task
+	for _, r := range tasks {
+		if r.Priority < t.Priority {
+			preemptible = append(preemptible, r)
+		}
+	}
+	sort.SliceStable(preemptible, func(i, j int) bool {
+		if preemptible[i].Priority != preemptible[j].Priority {
+			return preemptible[i].Priority < preemptible[j].Priority
+		}
+		return preemptible[i].StartTime.After(preemptible[j].StartTime)
+	})
+
+	freed := *n
+	for i, v := range preemptible {
+		freed.MemoryAllocated -= v.Memory
+		freed.DiskAllocated -= v.Disk * Gigabyte
+		freed.CPUAllocated -= v.CPU
+		if len(s.SelectCandidateNodes(t, []*node.Node{&freed})) == 1 {
+			return preemptible[:i+1], true
+		}
+	}
+	return nil, false
+}
+
+// lessDisruptive reports whether stopping a is less disruptive than stopping b.
+func lessDisruptive(a, b []task.Task) bool {
+	if maxA, maxB := a[len(a)-1].Priority, b[len(b)-1].Priority; maxA != maxB {
+		return maxA < maxB
+	}
+	return len(a) < len(b)
+}
@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"orchestra/node"
+	"orchestra/task"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// runningTask returns a task of the given priority holding memory GiB, started at the given minute.
+func runningTask(priority, memory, minute int) task.Task {
+	return task.Task{
+		ID:        uuid.New(),
+		Priority:  priority,
+		Memory:    memory << 30,
+		StartTime: time.Date(2024, 1, 1, 0, minute, 0, 0, time.UTC),
+	}
+}
+
+// allocate returns a node with 4GiB of memory, allocated to the given tasks.
+func allocate(name string, tasks ...task.Task) *node.Node {
+	n := newNode(name, 4, 4<<30)
+	for _, t := range tasks {
+		n.MemoryAllocated += t.Memory
+	}
+	return n
+}
+
+func TestSelectVictims(t *testing.T) {
+	older, newer := runningTask(0, 2, 1), runningTask(0, 2, 2)
+	important := runningTask(5, 4, 1)
+	low1, low2 := runningTask(1, 2, 1), runningTask(1, 2, 2)
+
+	tests := []struct {
+		name    string
+		running map[string][]task.Task
+		task    task.Task
+		want    string
+		victims []task.Task
+	}{
+		{
+			name:    "most recently started first",
+			running: map[string][]task.Task{"a": {older, newer}, "b": {important}},
+			task:    task.Task{Priority: 10, Memory: 2 << 30},
+			want:    "a",
+			victims: []task.Task{newer},
+		},
+		{
+			name:    "lowest priority victims over fewer victims",
+			running: map[string][]task.Task{"a": {important}, "b": {low1, low2}},
+			task:    task.Task{Priority: 10, Memory: 4 << 30},
+			want:    "b",
+			victims: []task.Task{low2, low1},
+		},
+		{
+			name:    "fewer victims at the same priority",
+			running: map[string][]task.Task{"a": {low1, low2}, "b": {runningTask(1, 4, 1)}},
+			task:    task.Task{Priority: 10, Memory: 4 << 30},
+			want:    "b",
+		},
+		{
+			name:    "only lower priority tasks",
+			running: map[string][]task.Task{"a": {important}, "b": {important}},
+			task:    task.Task{Priority: 5, Memory: 1 << 30},
+		},
+		{
+			name:    "too big for any node",
+			running: map[string][]task.Task{"a": {older, newer}, "b": {low1, low2}},
+			task:    task.Task{Priority: 10, Memory: 8 << 30},
+		},
+	}
+
+	for _, tt := range tests {
+		nodes := []*node.Node{allocate("a", tt.running["a"]...), allocate("b", tt.running["b"]...)}
+		n, victims := SelectVictims(&RoundRobin{}, tt.task, nodes, tt.running)
+		switch {
+		case tt.want == "" && n != nil:
+			t.Errorf("%s: SelectVictims() = %s, want no node", tt.name, n.Name)
+		case tt.want != "" && (n == nil || n.Name != tt.want):
+			t.Errorf("%s: SelectVictims() = %v, want %s", tt.name, n, tt.want)
+		case tt.victims != nil && !sameTasks(victims, tt.victims):
+			t.Errorf("%s: SelectVictims() victims = %v, want %v", tt.name, victims, tt.victims)
+		}
+		if nodes[0].MemoryAllocated != memoryOf(tt.running["a"]) {
+			t.Errorf("%s: SelectVictims() changed the allocations of the nodes it was given", tt.name)
+		}
+	}
+}
+
+func sameTasks(a, b []task.Task) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ID != b[i].ID {
+			return false
+		}
+	}
+	return true
+}
+
+func memoryOf(tasks []task.Task) int {
+	var memory int
+	for _, t := range tasks {
+		memory += t.Memory
+	}
+	return memory
+}
@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"orchestra/node"
+	"orchestra/task"
+)
+
+// RoundRobin places tasks in turn on the nodes that have room for them.
+type RoundRobin struct {
+	LastWorker int // LastWorker is the position, among the candidates, of the node that received the last task.
+}
+
+func (r *RoundRobin) Name() string {
+	return "roundrobin"
+}
+
+// SelectCandidateNodes keeps the nodes with enough allocatable CPU, memory and disk left for the task,
+// whose labels meet its constraints and whose taints it tolerates.
+func (r *RoundRobin) SelectCandidateNodes(t task.Task, nodes []*node.Node) []*node.Node {
+	return filter(t, nodes, r.filters()...)
+}
+
+func (r *RoundRobin) filters() []filterPlugin {
+	return []filterPlugin{cpuFilter{}, memoryFilter{}, diskFilter{}, constraintsFilter{}, taintsFilter{}}
+}
+
+func (r *RoundRobin) scorers() []scorePlugin {
+	return []scorePlugin{affinityScore{}, taintScore{}}
+}
+
+// Score gives the node after the last one that received a task the top score and every other node zero.
+// The task's affinities and the PreferNoSchedule taints it doesn't tolerate are added on top.
+func (r *RoundRobin) Score(t task.Task, nodes []*node.Node) map[string]float64 {
+	return addScores(t, nodes, r.strategyScore(t, nodes), r.scorers()...)
+}
+
+// strategyScore gives the node after the last one that received a task 1 and every other node 0.
+func (r *RoundRobin) strategyScore(t task.Task, nodes []*node.Node) map[string]float64 {
+	scores := make(map[string]float64, len(nodes))
+	next := r.next(len(nodes))
+	for i, n := range nodes {
+		if i == next {
+			scores[n.Name] = 1
+		} else {
+			scores[n.Name] = 0
+		}
+	}
+	return scores
+}
+
+// next is the position, among count candidates, of the node after the one that received the last task.
+func (r *RoundRobin) next(count int) int {
+	if r.LastWorker+1 < count {
+		return r.LastWorker + 1
+	}
+	return 0
+}
+
+// Pick returns the node with the top score, and moves on from it: the next task goes to the node after it,
+// even if the task's preferences took it past the node whose turn it was.
+func (r *RoundRobin) Pick(scores map[string]float64, candidates []*node.Node) *node.Node {
+	picked := r.pick(scores, candidates)
+	for i, n := range candidates {
+		if n == picked {
+			r.LastWorker = i
+		}
+	}
+	return picked
+}
+
+// pick returns the node with the top score, without moving on.
+func (r *RoundRobin) pick(scores map[string]float64, candidates []*node.Node) *node.Node {
+	return pickHighest(scores, candidates)
+}
@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"orchestra/node"
+	"orchestra/task"
+	"slices"
+	"testing"
+)
+
+func TestRoundRobinSelectCandidateNodes(t *testing.T) {
+	ssd := newNode("ssd", 4, 8<<30)
+	ssd.Labels = map[string]string{"disk": "ssd"}
+	small := newNode("small", 1, 1<<30)
+	full := newNode("full", 4, 8<<30)
+	full.DiskAllocated = 100 * Gigabyte
+	tainted := newNode("tainted", 4, 8<<30)
+	tainted.Taints = []node.Taint{{Key: "dedicated", Value: "infra", Effect: node.NoSchedule}}
+	preferred := newNode("preferred", 4, 8<<30)
+	preferred.Taints = []node.Taint{{Key: "spot", Effect: node.PreferNoSchedule}}
+	nodes := []*node.Node{ssd, small, full, tainted, preferred}
+
+	tests := []struct {
+		name string
+		task task.Task
+		want []string
+	}{
+		{"no requests", task.Task{}, []string{"ssd", "small", "full", "preferred"}},
+		{"cpu", task.Task{CPU: 2}, []string{"ssd", "full", "preferred"}},
+		{"memory", task.Task{Memory: 2 << 30}, []string{"ssd", "full", "preferred"}},
+		{"disk", task.Task{Disk: 1}, []string{"ssd", "small", "preferred"}},
+		{"constraint", task.Task{Constraints: []task.Constraint{{Key: "disk", Operator: task.Equals, Values: []string{"ssd"}}}}, []string{"ssd"}},
+		{"toleration", task.Task{Tolerations: []task.Toleration{{Key: "dedicated", Operator: task.Exists}}}, []string{"ssd", "small", "full", "tainted", "preferred"}},
+	}
+	for _, tt := range tests {
+		r := &RoundRobin{}
+		if got := names(r.SelectCandidateNodes(tt.task, nodes)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: SelectCandidateNodes() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRoundRobinRotation(t *testing.T) {
+	nodes := []*node.Node{newNode("a", 4, 8<<30), newNode("b", 4, 8<<30), newNode("c", 4, 8<<30)}
+	r := &RoundRobin{LastWorker: len(nodes) - 1}
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, place(r, task.Task{}, nodes))
+	}
+	if want := []string{"a", "b", "c", "a"}; !slices.Equal(got, want) {
+		t.Errorf("tasks placed on %v, want %v", got, want)
+	}
+}
+
+// TestRoundRobinScoreDoesNotRotate checks that only Pick moves the scheduler on, so that scoring a task
+// that isn't placed doesn't skip a node.
+func TestRoundRobinScoreDoesNotRotate(t *testing.T) {
+	nodes := []*node.Node{newNode("a", 4, 8<<30), newNode("b", 4, 8<<30)}
+	r := &RoundRobin{LastWorker: len(nodes) - 1}
+
+	first := r.Score(task.Task{}, nodes)
+	second := r.Score(task.Task{}, nodes)
+	if first["a"] != 1 || second["a"] != 1 {
+		t.Errorf("Score() = %v then %v, want a on top both times", first, second)
+	}
+}
+
+// TestRoundRobinRotatesFromPickedNode checks that the next task goes to the node after the one the last task
+// was placed on, when the task's preferences took it past the node whose turn it was.
+func TestRoundRobinRotatesFromPickedNode(t *testing.T) {
+	a, b, c := newNode("a", 4, 8<<30), newNode("b", 4, 8<<30), newNode("c", 4, 8<<30)
+	a.Labels = map[string]string{"zone": "z2"}
+	c.Labels = map[string]string{"zone": "z1"}
+	nodes := []*node.Node{a, b, c}
+	r := &RoundRobin{LastWorker: len(nodes) - 1}
+
+	zone := task.Task{
+		Affinities:     []task.Constraint{{Key: "zone", Operator: task.Equals, Values: []string{"z1"}}},
+		AntiAffinities: []task.Constraint{{Key: "zone", Operator: task.Equals, Values: []string{"z2"}}},
+	}
+	if got := place(r, zone, nodes); got != "c" {
+		t.Fatalf("task preferring zone z1 over z2 placed on %q, want c", got)
+	}
+	if got := place(r, task.Task{}, nodes); got != "a" {
+		t.Errorf("next task placed on %q, want a, the node after c", got)
+	}
+}
+
+func TestRoundRobinPickWithoutCandidates(t *testing.T) {
+	r := &RoundRobin{LastWorker: 1}
+	if n := r.Pick(map[string]float64{}, nil); n != nil {
+		t.Errorf("Pick() = %v without candidates", n.Name)
+	}
+	if r.LastWorker != 1 {
+		t.Errorf("LastWorker = %d after picking nothing, want it left at 1", r.LastWorker)
+	}
+}
@@ -1,7 +1,57 @@
 package scheduler
 
+import (
+	"fmt"
+	"orchestra/node"
+	"orchestra/task"
+)
+
+// Gigabyte is the unit of a task's Disk request.
+const Gigabyte = 1 << 30
+
+// Scheduler decides which node a task is placed on, in three steps: it filters the nodes that
+// can run the task, scores each of them and picks one from the scores.
+//
+// Whatever their strategy, the schedulers of this package only keep the nodes that meet the task's
+// constraints and carry no NoSchedule or NoExecute taint it doesn't tolerate, and they add the
+// task's affinities and the PreferNoSchedule taints it doesn't tolerate to their scores.
 type Scheduler interface {
-	SelectCandidateNodes()
-	Score()
-	Pick()
+	// Name identifies the placement strategy of the scheduler, e.g. in the decision log.
+	Name() string
+
+	// SelectCandidateNodes returns the nodes, among the given ones, that are able to run the task.
+	SelectCandidateNodes(t task.Task, nodes []*node.Node) []*node.Node
+
+	// Score rates every candidate node for the task, keyed by node name. Higher scores are better.
+	Score(t task.Task, nodes []*node.Node) map[string]float64
+
+	// Pick returns the candidate to place the task on given its scores, or nil if there are no candidates.
+	Pick(scores map[string]float64, candidates []*node.Node) *node.Node
+}
+
+// pickHighest returns the candidate with the highest score, the earliest one winning ties.
+func pickHighest(scores map[string]float64, candidates []*node.Node) *node.Node {
+	var best *node.Node
+	for _, n := range candidates {
+		if best == nil || scores[n.Name] > scores[best.Name] {
+			best = n
+		}
+	}
+	return best
+}
+
+// New creates the scheduler with the given name: "roundrobin", "epvm", "binpack" or "spread".
+func New(name string) (Scheduler, error) {
+	switch name {
+	case "roundrobin":
+		return &RoundRobin{}, nil
+	case "epvm":
+		return &Epvm{}, nil
+	case "binpack":
+		return &BinPack{}, nil
+	case "spread":
+		return &Spread{}, nil
+	default:
+		return nil, fmt.Errorf("unknown scheduler %q", name)
+	}
 }
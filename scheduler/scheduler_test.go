@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"orchestra/node"
+	"orchestra/task"
+	"testing"
+)
+
+// newNode returns a ready node with the given cores and memory in bytes, and 100GB of disk.
+func newNode(name string, cores int, memory int) *node.Node {
+	return &node.Node{Name: name, Cores: cores, Memory: memory, Disk: 100 * Gigabyte, Status: node.Ready}
+}
+
+// place runs the filter, score and pick steps of the scheduler for the task, and returns the name of the
+// node picked, empty if none.
+func place(s Scheduler, t task.Task, nodes []*node.Node) string {
+	candidates := s.SelectCandidateNodes(t, nodes)
+	if n := s.Pick(s.Score(t, candidates), candidates); n != nil {
+		return n.Name
+	}
+	return ""
+}
+
+// names returns the names of the nodes.
+func names(nodes []*node.Node) []string {
+	var names []string
+	for _, n := range nodes {
+		names = append(names, n.Name)
+	}
+	return names
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range []string{"roundrobin", "epvm", "binpack", "spread"} {
+		s, err := New(name)
+		if err != nil {
+			t.Fatalf("New(%q) error = %v", name, err)
+		}
+		if s.Name() != name {
+			t.Errorf("New(%q).Name() = %q", name, s.Name())
+		}
+	}
+	if _, err := New("fastest"); err == nil {
+		t.Error("New() error = nil for an unknown scheduler")
+	}
+}
@@ -0,0 +1,85 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Operator is how a Constraint compares a node label with its values.
+type Operator string
+
+const (
+	// Equals requires the label to be set to the only value of the constraint.
+	Equals Operator = "equals"
+
+	// NotEquals requires the label to be unset or set to something else than the only value of the constraint.
+	NotEquals Operator = "not-equals"
+
+	// In requires the label to be set to one of the values of the constraint.
+	In Operator = "in"
+
+	// Exists requires the label to be set, whatever its value.
+	Exists Operator = "exists"
+)
+
+// Constraint is a condition on the labels of the node a task is placed on, e.g. that "disk" equals "ssd".
+type Constraint struct {
+	Key      string   // Key is the node label the constraint is about.
+	Operator Operator // Operator is how the label is compared with Values.
+	Values   []string // Values are what the label is compared with; Exists takes none.
+}
+
+// Validate reports whether the constraint is well formed.
+func (c Constraint) Validate() error {
+	if c.Key == "" {
+		return errors.New("constraint has no key")
+	}
+
+	switch c.Operator {
+	case Equals, NotEquals:
+		if len(c.Values) != 1 {
+			return fmt.Errorf("constraint on %q: %s takes exactly one value", c.Key, c.Operator)
+		}
+	case In:
+		if len(c.Values) == 0 {
+			return fmt.Errorf("constraint on %q: %s takes at least one value", c.Key, c.Operator)
+		}
+	case Exists:
+		if len(c.Values) != 0 {
+			return fmt.Errorf("constraint on %q: %s takes no value", c.Key, c.Operator)
+		}
+	default:
+		return fmt.Errorf("constraint on %q: unknown operator %q", c.Key, c.Operator)
+	}
+	return nil
+}
+
+// Matches reports whether a node with the given labels meets the constraint.
+func (c Constraint) Matches(labels map[string]string) bool {
+	value, ok := labels[c.Key]
+	switch c.Operator {
+	case Equals:
+		return ok && len(c.Values) == 1 && value == c.Values[0]
+	case NotEquals:
+		return !ok || len(c.Values) != 1 || value != c.Values[0]
+	case In:
+		return ok && slices.Contains(c.Values, value)
+	case Exists:
+		return ok
+	default:
+		return false
+	}
+}
+
+func (c Constraint) String() string {
+	switch c.Operator {
+	case Exists:
+		return fmt.Sprintf("%s exists", c.Key)
+	case Equals, NotEquals:
+		if len(c.Values) == 1 {
+			return fmt.Sprintf("%s %s %s", c.Key, c.Operator, c.Values[0])
+		}
+	}
+	return fmt.Sprintf("%s %s %v", c.Key, c.Operator, c.Values)
+}
@@ -0,0 +1,72 @@
+package task
+
+import "testing"
+
+func TestConstraintMatches(t *testing.T) {
+	labels := map[string]string{"disk": "ssd", "rack": "r1"}
+
+	tests := []struct {
+		c    Constraint
+		want bool
+	}{
+		{Constraint{Key: "disk", Operator: Equals, Values: []string{"ssd"}}, true},
+		{Constraint{Key: "disk", Operator: Equals, Values: []string{"hdd"}}, false},
+		{Constraint{Key: "zone", Operator: Equals, Values: []string{"z1"}}, false},
+		{Constraint{Key: "disk", Operator: NotEquals, Values: []string{"hdd"}}, true},
+		{Constraint{Key: "disk", Operator: NotEquals, Values: []string{"ssd"}}, false},
+		{Constraint{Key: "zone", Operator: NotEquals, Values: []string{"z1"}}, true},
+		{Constraint{Key: "rack", Operator: In, Values: []string{"r1", "r2"}}, true},
+		{Constraint{Key: "rack", Operator: In, Values: []string{"r2", "r3"}}, false},
+		{Constraint{Key: "zone", Operator: In, Values: []string{"z1"}}, false},
+		{Constraint{Key: "rack", Operator: Exists}, true},
+		{Constraint{Key: "zone", Operator: Exists}, false},
+		{Constraint{Key: "disk", Operator: "like", Values: []string{"ssd"}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.c.Matches(labels); got != tt.want {
+			t.Errorf("%s: Matches(%v) = %v, want %v", tt.c, labels, got, tt.want)
+		}
+	}
+
+	if !(Constraint{Key: "disk", Operator: NotEquals, Values: []string{"ssd"}}).Matches(nil) {
+		t.Error("not-equals doesn't match a node without labels")
+	}
+}
+
+func TestConstraintValidate(t *testing.T) {
+	tests := []struct {
+		c     Constraint
+		valid bool
+	}{
+		{Constraint{Key: "disk", Operator: Equals, Values: []string{"ssd"}}, true},
+		{Constraint{Key: "disk", Operator: Equals}, false},
+		{Constraint{Key: "disk", Operator: NotEquals, Values: []string{"ssd", "hdd"}}, false},
+		{Constraint{Key: "rack", Operator: In, Values: []string{"r1", "r2"}}, true},
+		{Constraint{Key: "rack", Operator: In}, false},
+		{Constraint{Key: "rack", Operator: Exists}, true},
+		{Constraint{Key: "rack", Operator: Exists, Values: []string{"r1"}}, false},
+		{Constraint{Key: "disk", Operator: "like", Values: []string{"ssd"}}, false},
+		{Constraint{Operator: Exists}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.c.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%+v: Validate() error = %v, want valid %v", tt.c, err, tt.valid)
+		}
+	}
+}
+
+func TestConstraintString(t *testing.T) {
+	tests := []struct {
+		c    Constraint
+		want string
+	}{
+		{Constraint{Key: "disk", Operator: Equals, Values: []string{"ssd"}}, "disk equals ssd"},
+		{Constraint{Key: "rack", Operator: In, Values: []string{"r1", "r2"}}, "rack in [r1 r2]"},
+		{Constraint{Key: "rack", Operator: Exists}, "rack exists"},
+	}
+	for _, tt := range tests {
+		if got := tt.c.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
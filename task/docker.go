@@ -0,0 +1,356 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// Docker is the Runtime that runs tasks as Docker containers.
+type Docker struct {
+	Client *client.Client
+
+	storageOnce  sync.Once // storageOnce guards the lookup of sizedStorage.
+	sizedStorage bool      // sizedStorage tells whether the daemon's storage driver can limit the size of a container's filesystem.
+}
+
+var (
+	_ VolumeManager = (*Docker)(nil)
+	_ Executor      = (*Docker)(nil)
+)
+
+// NewDocker creates a Docker runtime talking to the daemon configured in the environment.
+func NewDocker() (*Docker, error) {
+	c, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("unable to create docker client: %w", err)
+	}
+	return &Docker{Client: c}, nil
+}
+
+func (d *Docker) Pull(ctx context.Context, ref string) error {
+	rc, err := d.Client.ImagePull(ctx, ref, image.PullOptions{})
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	// the pull only completes once its progress has been read.
+	_, err = io.Copy(os.Stdout, rc)
+	return err
+}
+
+// Create creates a container limited to the CPU, memory and disk of the config, publishing only its ports.
+// Missing named volumes are created first, and the disk limit only applies when the storage driver supports it.
+//
+// The daemon restarts the container in place according to the config's RestartPolicy, see dockerRestartPolicy,
+// and a container it's restarting is reported as running, so that the manager only restarts the task, possibly
+// elsewhere, when the container is gone.
+func (d *Docker) Create(ctx context.Context, config Config) (string, error) {
+	ports, published, err := portBindings(config.ExposedPorts, config.PortBindings)
+	if err != nil {
+		return "", err
+	}
+
+	r := container.Resources{
+		Memory:   config.Memory,
+		NanoCPUs: int64(config.CPU * 1e9),
+	}
+	cc := container.Config{
+		Image:        config.Image,
+		Cmd:          config.Cmd,
+		Env:          config.Env,
+		Labels:       config.Labels,
+		ExposedPorts: ports,
+	}
+	mounts, err := d.mounts(ctx, config.Mounts)
+	if err != nil {
+		return "", err
+	}
+	hc := container.HostConfig{
+		RestartPolicy: container.RestartPolicy{Name: dockerRestartPolicy(config.RestartPolicy)},
+		Resources:     r,
+		PortBindings:  published,
+		Mounts:        mounts,
+	}
+	if config.Disk > 0 {
+		if d.supportsStorageSize(ctx) {
+			hc.StorageOpt = map[string]string{"size": strconv.FormatInt(config.Disk, 10)}
+		} else {
+			log.Printf("The storage driver can't limit the disk of container %s to %d bytes, leaving it unlimited", config.Name, config.Disk)
+		}
+	}
+
+	resp, err := d.Client.ContainerCreate(ctx, &cc, &hc, nil, nil, config.Name)
+	if err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// dockerRestartPolicy returns the Docker restart policy for a task's RestartPolicy. Tasks without a policy
+// are restarted on failure, and "never" is Docker's "no".
+func dockerRestartPolicy(policy string) container.RestartPolicyMode {
+	switch policy {
+	case "always":
+		return container.RestartPolicyAlways
+	case "no", "never":
+		return container.RestartPolicyDisabled
+	default:
+		return container.RestartPolicyOnFailure
+	}
+}
+
+// mounts translates the mounts of a config for the daemon, creating the named volumes that don't exist yet.
+func (d *Docker) mounts(ctx context.Context, mounts []Mount) ([]mount.Mount, error) {
+	translated := make([]mount.Mount, 0, len(mounts))
+	for _, m := range mounts {
+		if err := m.Validate(); err != nil {
+			return nil, err
+		}
+
+		dm := mount.Mount{Source: m.Source, Target: m.Target, ReadOnly: m.ReadOnly}
+		switch m.Type {
+		case Bind:
+			dm.Type = mount.TypeBind
+		case Volume:
+			dm.Type = mount.TypeVolume
+			if err := d.ensureVolume(ctx, m.Source); err != nil {
+				return nil, err
+			}
+		case Tmpfs:
+			dm.Type = mount.TypeTmpfs
+			if m.Size > 0 {
+				dm.TmpfsOptions = &mount.TmpfsOptions{SizeBytes: m.Size}
+			}
+		}
+		translated = append(translated, dm)
+	}
+	return translated, nil
+}
+
+// ensureVolume creates the named volume, labelled as managed by the worker, unless it already exists.
+func (d *Docker) ensureVolume(ctx context.Context, name string) error {
+	_, err := d.Client.VolumeInspect(ctx, name)
+	if err == nil {
+		return nil
+	}
+	if !errdefs.IsNotFound(err) {
+		return fmt.Errorf("unable to inspect volume %s: %w", name, err)
+	}
+
+	log.Printf("Creating volume %s", name)
+	_, err = d.Client.VolumeCreate(ctx, volume.CreateOptions{
+		Name:   name,
+		Labels: map[string]string{LabelVolumeManaged: "true"},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to create volume %s: %w", name, err)
+	}
+	return nil
+}
+
+func (d *Docker) Volumes(ctx context.Context) ([]VolumeInfo, error) {
+	resp, err := d.Client.VolumeList(ctx, volume.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", LabelVolumeManaged)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]VolumeInfo, 0, len(resp.Volumes))
+	for _, v := range resp.Volumes {
+		info := VolumeInfo{Name: v.Name, Driver: v.Driver, Mountpoint: v.Mountpoint}
+		info.CreatedAt, _ = time.Parse(time.RFC3339, v.CreatedAt)
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (d *Docker) RemoveVolume(ctx context.Context, name string) error {
+	v, err := d.Client.VolumeInspect(ctx, name)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return fmt.Errorf("%w: %v", ErrVolumeNotFound, err)
+		}
+		return err
+	}
+	if _, ok := v.Labels[LabelVolumeManaged]; !ok {
+		return fmt.Errorf("%w: %s wasn't created by the worker", ErrVolumeNotFound, name)
+	}
+
+	err = d.Client.VolumeRemove(ctx, name, false)
+	switch {
+	case err == nil:
+		return nil
+	case errdefs.IsConflict(err):
+		return fmt.Errorf("%w: %v", ErrVolumeInUse, err)
+	case errdefs.IsNotFound(err):
+		return fmt.Errorf("%w: %v", ErrVolumeNotFound, err)
+	default:
+		return err
+	}
+}
+
+// supportsStorageSize reports whether the daemon's storage driver accepts a "size" storage option:
+// btrfs, zfs and devicemapper do, and overlay2 does when it's backed by xfs.
+func (d *Docker) supportsStorageSize(ctx context.Context) bool {
+	d.storageOnce.Do(func() {
+		info, err := d.Client.Info(ctx)
+		if err != nil {
+			log.Printf("Unable to look up the storage driver of the Docker daemon: %v", err)
+			return
+		}
+
+		switch info.Driver {
+		case "btrfs", "zfs", "devicemapper":
+			d.sizedStorage = true
+		case "overlay2":
+			for _, status := range info.DriverStatus {
+				if status[0] == "Backing Filesystem" && status[1] == "xfs" {
+					d.sizedStorage = true
+				}
+			}
+		}
+	})
+	return d.sizedStorage
+}
+
+func (d *Docker) Start(ctx context.Context, id string) error {
+	return notFound(d.Client.ContainerStart(ctx, id, container.StartOptions{}))
+}
+
+func (d *Docker) Stop(ctx context.Context, id string) error {
+	return notFound(d.Client.ContainerStop(ctx, id, container.StopOptions{}))
+}
+
+func (d *Docker) Remove(ctx context.Context, id string) error {
+	return notFound(d.Client.ContainerRemove(ctx, id, container.RemoveOptions{}))
+}
+
+func (d *Docker) Inspect(ctx context.Context, id string) (ContainerInfo, error) {
+	resp, err := d.Client.ContainerInspect(ctx, id)
+	if err != nil {
+		return ContainerInfo{}, notFound(err)
+	}
+
+	info := ContainerInfo{ID: resp.ID}
+	if resp.Config != nil {
+		info.Image = resp.Config.Image
+		info.Labels = resp.Config.Labels
+	}
+	if resp.State != nil {
+		info.State = resp.State.Status
+		info.ExitCode = resp.State.ExitCode
+		info.StartedAt, _ = time.Parse(time.RFC3339Nano, resp.State.StartedAt)
+		info.FinishedAt, _ = time.Parse(time.RFC3339Nano, resp.State.FinishedAt)
+	}
+	if ns := resp.NetworkSettings; ns != nil {
+		info.IPAddress = ns.IPAddress
+		for _, endpoint := range ns.Networks {
+			if info.IPAddress == "" && endpoint != nil {
+				info.IPAddress = endpoint.IPAddress
+			}
+		}
+	}
+	return info, nil
+}
+
+func (d *Docker) Exec(ctx context.Context, id string, cmd []string) (int, string, error) {
+	exec, err := d.Client.ContainerExecCreate(ctx, id, container.ExecOptions{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return 0, "", notFound(err)
+	}
+
+	attached, err := d.Client.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return 0, "", err
+	}
+	defer attached.Close()
+
+	var output bytes.Buffer
+	if _, err := stdcopy.StdCopy(&output, &output, attached.Reader); err != nil {
+		return 0, output.String(), err
+	}
+
+	inspect, err := d.Client.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return 0, output.String(), err
+	}
+	return inspect.ExitCode, output.String(), nil
+}
+
+func (d *Docker) List(ctx context.Context) ([]ContainerInfo, error) {
+	containers, err := d.Client.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", LabelTaskID)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]ContainerInfo, 0, len(containers))
+	for _, c := range containers {
+		info, err := d.Inspect(ctx, c.ID)
+		if err != nil {
+			// the container may have been removed since it was listed.
+			continue
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (d *Docker) Logs(ctx context.Context, id string, stdout, stderr io.Writer) error {
+	out, err := d.Client.ContainerLogs(ctx, id, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+	})
+	if err != nil {
+		return notFound(err)
+	}
+	defer out.Close()
+
+	_, err = stdcopy.StdCopy(stdout, stderr, out)
+	return err
+}
+
+func (d *Docker) Wait(ctx context.Context, id string) (int, error) {
+	statusCh, errCh := d.Client.ContainerWait(ctx, id, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		return 0, notFound(err)
+	case status := <-statusCh:
+		if status.Error != nil {
+			return int(status.StatusCode), fmt.Errorf("waiting for container %s: %s", id, status.Error.Message)
+		}
+		return int(status.StatusCode), nil
+	}
+}
+
+// notFound turns the daemon's "no such container" errors into ErrNotFound.
+func notFound(err error) error {
+	if err != nil && errdefs.IsNotFound(err) {
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	return err
+}
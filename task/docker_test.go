@@ -0,0 +1,26 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestDockerRestartPolicy(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   container.RestartPolicyMode
+	}{
+		{"", container.RestartPolicyOnFailure},
+		{"on-failure", container.RestartPolicyOnFailure},
+		{"always", container.RestartPolicyAlways},
+		{"no", container.RestartPolicyDisabled},
+		{"never", container.RestartPolicyDisabled},
+	}
+
+	for _, tt := range tests {
+		if got := dockerRestartPolicy(tt.policy); got != tt.want {
+			t.Errorf("dockerRestartPolicy(%q) = %q, want %q", tt.policy, got, tt.want)
+		}
+	}
+}
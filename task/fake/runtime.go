@@ -0,0 +1,507 @@
+// Package fake provides an in-memory task.Runtime whose behaviour is scripted by the caller,
+// so that the worker and the manager can be exercised without a Docker daemon.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"orchestra/task"
+	"sync"
+	"time"
+)
+
+// Op names a method of task.Runtime, so that failures can be scripted per operation.
+type Op string
+
+const (
+	Pull    Op = "pull"
+	Create  Op = "create"
+	Start   Op = "start"
+	Stop    Op = "stop"
+	Remove  Op = "remove"
+	Inspect Op = "inspect"
+	List    Op = "list"
+	Logs    Op = "logs"
+	Wait    Op = "wait"
+
+	Volumes      Op = "volumes"
+	RemoveVolume Op = "remove-volume"
+	Exec         Op = "exec"
+)
+
+// Call records one call made to the Runtime.
+type Call struct {
+	Op  Op     // Op is the operation that was called.
+	Arg string // Arg is the image for Pull, the container name for Create, the volume name for RemoveVolume and the container ID otherwise.
+}
+
+type execResult struct {
+	code   int
+	output string
+}
+
+type container struct {
+	info   task.ContainerInfo
+	name   string
+	config task.Config
+	stdout string
+	stderr string
+	exec   execResult    // exec is what commands run in the container return.
+	exited chan struct{} // exited is closed when the container stops running.
+}
+
+// Runtime is an in-memory task.Runtime. Containers only change state when the Runtime is told to,
+// through its own methods or through Exit and Crash, which makes every run deterministic.
+//
+// The zero value isn't usable; create one with NewRuntime.
+type Runtime struct {
+	Latency time.Duration    // Latency is how long every operation takes; it's cut short when the context is done.
+	Now     func() time.Time // Now is the clock used for start and finish times.
+
+	mu         sync.Mutex
+	images     map[string]bool
+	pullErrors map[string]error
+	failNext   map[Op][]error
+	containers map[string]*container
+	volumes    map[string]task.VolumeInfo
+	calls      []Call
+	nextID     int
+}
+
+var _ task.Runtime = (*Runtime)(nil)
+var _ task.VolumeManager = (*Runtime)(nil)
+var _ task.Executor = (*Runtime)(nil)
+
+// NewRuntime creates a Runtime that has no images and no containers.
+func NewRuntime() *Runtime {
+	return &Runtime{
+		Now:        time.Now,
+		images:     make(map[string]bool),
+		pullErrors: make(map[string]error),
+		failNext:   make(map[Op][]error),
+		containers: make(map[string]*container),
+		volumes:    make(map[string]task.VolumeInfo),
+	}
+}
+
+// FailPull makes every pull of the image fail with err, as when the image doesn't exist in the registry.
+// A nil err makes the image pullable again.
+func (r *Runtime) FailPull(image string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil {
+		delete(r.pullErrors, image)
+		return
+	}
+	r.pullErrors[image] = err
+}
+
+// FailNext makes the next call to op fail with err. Calling it several times queues several failures.
+func (r *Runtime) FailNext(op Op, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failNext[op] = append(r.failNext[op], err)
+}
+
+// Exit makes the process of a running container exit with the given code.
+func (r *Runtime) Exit(id string, code int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.containers[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", task.ErrNotFound, id)
+	}
+	if !c.info.Running() {
+		return fmt.Errorf("container %s is not running", id)
+	}
+	r.exit(c, code)
+	return nil
+}
+
+// Restarting makes the process of a running container exit with the given code, leaving the container in the
+// "restarting" state of a container its runtime is about to start again because of its restart policy.
+func (r *Runtime) Restarting(id string, code int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.containers[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", task.ErrNotFound, id)
+	}
+	if !c.info.Running() {
+		return fmt.Errorf("container %s is not running", id)
+	}
+	c.info.State = "restarting"
+	c.info.ExitCode = code
+	c.info.FinishedAt = r.Now().UTC()
+	return nil
+}
+
+// Crash makes a running container die the way a container killed by the kernel does.
+func (r *Runtime) Crash(id string) error {
+	return r.Exit(id, 137)
+}
+
+// Vanish removes a container behind the worker's back, as if it was removed by hand.
+func (r *Runtime) Vanish(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.containers[id]; ok {
+		if c.info.Running() {
+			r.exit(c, 137)
+		}
+		delete(r.containers, id)
+	}
+}
+
+// WriteLogs appends output to what a container has written to its stdout and stderr.
+func (r *Runtime) WriteLogs(id, stdout, stderr string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.containers[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", task.ErrNotFound, id)
+	}
+	c.stdout += stdout
+	c.stderr += stderr
+	return nil
+}
+
+// AddContainer puts a container in the runtime as if it had been left behind by an earlier run of the worker.
+// It returns the ID of the container, which is info.ID when set.
+func (r *Runtime) AddContainer(info task.ContainerInfo) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if info.ID == "" {
+		info.ID = r.newID()
+	}
+	c := &container{info: info, exited: make(chan struct{})}
+	if !info.Running() {
+		close(c.exited)
+	}
+	r.containers[info.ID] = c
+	return info.ID
+}
+
+// Containers reports every container in the runtime.
+func (r *Runtime) Containers() []task.ContainerInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	infos := make([]task.ContainerInfo, 0, len(r.containers))
+	for _, c := range r.containers {
+		infos = append(infos, c.info)
+	}
+	return infos
+}
+
+// Config returns the config a container was created with.
+func (r *Runtime) Config(id string) (task.Config, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.containers[id]
+	if !ok {
+		return task.Config{}, false
+	}
+	return c.config, true
+}
+
+// Calls returns the calls made to the runtime so far, in order.
+func (r *Runtime) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call{}, r.calls...)
+}
+
+func (r *Runtime) Pull(ctx context.Context, image string) error {
+	if err := r.begin(ctx, Pull, image); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err, ok := r.pullErrors[image]; ok {
+		return err
+	}
+	r.images[image] = true
+	return nil
+}
+
+func (r *Runtime) Create(ctx context.Context, config task.Config) (string, error) {
+	if err := r.begin(ctx, Create, config.Name); err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.images[config.Image] {
+		return "", fmt.Errorf("no such image: %s", config.Image)
+	}
+	for id, c := range r.containers {
+		if config.Name != "" && c.name == config.Name {
+			return "", fmt.Errorf("the container name %q is already in use by container %s", config.Name, id)
+		}
+	}
+
+	for _, m := range config.Mounts {
+		if _, ok := r.volumes[m.Source]; m.Type == task.Volume && !ok {
+			r.volumes[m.Source] = task.VolumeInfo{Name: m.Source, Driver: "local", CreatedAt: r.Now().UTC()}
+		}
+	}
+
+	id := r.newID()
+	r.containers[id] = &container{
+		info: task.ContainerInfo{
+			ID:     id,
+			Image:  config.Image,
+			State:  "created",
+			Labels: config.Labels,
+		},
+		name:   config.Name,
+		config: config,
+		exited: make(chan struct{}),
+	}
+	return id, nil
+}
+
+func (r *Runtime) Start(ctx context.Context, id string) error {
+	if err := r.begin(ctx, Start, id); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.containers[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", task.ErrNotFound, id)
+	}
+	if c.info.Running() {
+		return nil
+	}
+	if c.info.State != "created" {
+		c.exited = make(chan struct{})
+	}
+	c.info.State = "running"
+	c.info.ExitCode = 0
+	c.info.StartedAt = r.Now().UTC()
+	c.info.FinishedAt = time.Time{}
+	return nil
+}
+
+func (r *Runtime) Stop(ctx context.Context, id string) error {
+	if err := r.begin(ctx, Stop, id); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.containers[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", task.ErrNotFound, id)
+	}
+	if c.info.Running() {
+		r.exit(c, 0)
+	}
+	return nil
+}
+
+func (r *Runtime) Remove(ctx context.Context, id string) error {
+	if err := r.begin(ctx, Remove, id); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.containers[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", task.ErrNotFound, id)
+	}
+	if c.info.Running() {
+		return fmt.Errorf("cannot remove container %s: container is running", id)
+	}
+	delete(r.containers, id)
+	return nil
+}
+
+// ScriptExec makes every command run in the container from now on exit with code and output, e.g. so that
+// its exec health checks fail.
+func (r *Runtime) ScriptExec(id string, code int, output string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.containers[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", task.ErrNotFound, id)
+	}
+	c.exec = execResult{code: code, output: output}
+	return nil
+}
+
+func (r *Runtime) Exec(ctx context.Context, id string, cmd []string) (int, string, error) {
+	if err := r.begin(ctx, Exec, id); err != nil {
+		return 0, "", err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.containers[id]
+	if !ok {
+		return 0, "", fmt.Errorf("%w: %s", task.ErrNotFound, id)
+	}
+	if !c.info.Running() {
+		return 0, "", fmt.Errorf("container %s is not running", id)
+	}
+	return c.exec.code, c.exec.output, nil
+}
+
+func (r *Runtime) Volumes(ctx context.Context) ([]task.VolumeInfo, error) {
+	if err := r.begin(ctx, Volumes, ""); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	infos := make([]task.VolumeInfo, 0, len(r.volumes))
+	for _, v := range r.volumes {
+		infos = append(infos, v)
+	}
+	return infos, nil
+}
+
+func (r *Runtime) RemoveVolume(ctx context.Context, name string) error {
+	if err := r.begin(ctx, RemoveVolume, name); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.volumes[name]; !ok {
+		return fmt.Errorf("%w: %s", task.ErrVolumeNotFound, name)
+	}
+	for id, c := range r.containers {
+		for _, m := range c.config.Mounts {
+			if m.Type == task.Volume && m.Source == name {
+				return fmt.Errorf("%w: volume %s is used by container %s", task.ErrVolumeInUse, name, id)
+			}
+		}
+	}
+	delete(r.volumes, name)
+	return nil
+}
+
+func (r *Runtime) Inspect(ctx context.Context, id string) (task.ContainerInfo, error) {
+	if err := r.begin(ctx, Inspect, id); err != nil {
+		return task.ContainerInfo{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.containers[id]
+	if !ok {
+		return task.ContainerInfo{}, fmt.Errorf("%w: %s", task.ErrNotFound, id)
+	}
+	return c.info, nil
+}
+
+func (r *Runtime) List(ctx context.Context) ([]task.ContainerInfo, error) {
+	if err := r.begin(ctx, List, ""); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var infos []task.ContainerInfo
+	for _, c := range r.containers {
+		if _, ok := c.info.Labels[task.LabelTaskID]; ok {
+			infos = append(infos, c.info)
+		}
+	}
+	return infos, nil
+}
+
+func (r *Runtime) Logs(ctx context.Context, id string, stdout, stderr io.Writer) error {
+	if err := r.begin(ctx, Logs, id); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	c, ok := r.containers[id]
+	if !ok {
+		r.mu.Unlock()
+		return fmt.Errorf("%w: %s", task.ErrNotFound, id)
+	}
+	out, errOut := c.stdout, c.stderr
+	r.mu.Unlock()
+
+	if _, err := io.WriteString(stdout, out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(stderr, errOut)
+	return err
+}
+
+func (r *Runtime) Wait(ctx context.Context, id string) (int, error) {
+	if err := r.begin(ctx, Wait, id); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	c, ok := r.containers[id]
+	if !ok {
+		r.mu.Unlock()
+		return 0, fmt.Errorf("%w: %s", task.ErrNotFound, id)
+	}
+	exited := c.exited
+	r.mu.Unlock()
+
+	select {
+	case <-exited:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return c.info.ExitCode, nil
+}
+
+// begin records a call, waits for the configured latency and returns the failure scripted for op, if any.
+func (r *Runtime) begin(ctx context.Context, op Op, arg string) error {
+	r.mu.Lock()
+	r.calls = append(r.calls, Call{Op: op, Arg: arg})
+	latency := r.Latency
+	var err error
+	if failures := r.failNext[op]; len(failures) > 0 {
+		err, r.failNext[op] = failures[0], failures[1:]
+	}
+	r.mu.Unlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// exit stops a running container with the given exit code. The caller must hold r.mu.
+func (r *Runtime) exit(c *container, code int) {
+	c.info.State = "exited"
+	c.info.ExitCode = code
+	c.info.FinishedAt = r.Now().UTC()
+	close(c.exited)
+}
+
+// newID returns a new container ID. The caller must hold r.mu.
+func (r *Runtime) newID() string {
+	r.nextID++
+	return fmt.Sprintf("fake-%012d", r.nextID)
+}
@@ -0,0 +1,118 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// HealthCheckType is how a HealthCheck probes a task.
+type HealthCheckType string
+
+const (
+	// HTTPCheck sends a GET request to the task and expects a 2xx or 3xx response.
+	HTTPCheck HealthCheckType = "http"
+
+	// TCPCheck expects the task to accept a TCP connection.
+	TCPCheck HealthCheckType = "tcp"
+
+	// ExecCheck runs a command inside the task's container and expects it to exit with 0.
+	ExecCheck HealthCheckType = "exec"
+)
+
+const (
+	// DefaultHealthInterval is the time between two checks of a HealthCheck that doesn't give an Interval.
+	DefaultHealthInterval = 10 * time.Second
+
+	// DefaultHealthTimeout is how long a check of a HealthCheck that doesn't give a Timeout may take.
+	DefaultHealthTimeout = 2 * time.Second
+
+	// DefaultHealthFailureThreshold is how many checks in a row must fail for a task whose HealthCheck
+	// doesn't give a FailureThreshold to be Unhealthy.
+	DefaultHealthFailureThreshold = 3
+
+	// MaxHealthHistory is the number of check results kept on a task.
+	MaxHealthHistory = 10
+)
+
+// Health is what the health checks of a task last concluded. It's empty until then.
+type Health string
+
+const (
+	// Healthy means the last check of the task passed.
+	Healthy Health = "healthy"
+
+	// Unhealthy means the last FailureThreshold checks of the task failed.
+	Unhealthy Health = "unhealthy"
+)
+
+// HealthCheck tells the worker how to tell whether a running task is actually working.
+type HealthCheck struct {
+	Type             HealthCheckType // Type is how the task is probed.
+	Port             string          // Port is the container port, e.g. "8080" or "8080/tcp", HTTP and TCP checks connect to.
+	Path             string          // Path is the path HTTP checks request; it defaults to "/".
+	Cmd              []string        // Cmd is the command exec checks run in the container.
+	Interval         time.Duration   // Interval is the time between two checks, in nanoseconds.
+	Timeout          time.Duration   // Timeout is how long a check may take before it fails, in nanoseconds.
+	FailureThreshold int             // FailureThreshold is how many checks in a row must fail for the task to be Unhealthy.
+}
+
+// HealthResult is the outcome of one check of a task.
+type HealthResult struct {
+	Time    time.Time // Time is when the check started.
+	Healthy bool      // Healthy tells whether the check passed.
+	Output  string    // Output is what the check got back, e.g. the HTTP status, or why it failed.
+}
+
+// Executor is implemented by the runtimes that can run a command in a running container, as exec checks need.
+type Executor interface {
+	// Exec runs cmd in the container and returns its exit code and what it wrote to stdout and stderr.
+	Exec(ctx context.Context, id string, cmd []string) (int, string, error)
+}
+
+// WithDefaults returns the check with the defaults in place of the settings it doesn't give.
+func (h HealthCheck) WithDefaults() HealthCheck {
+	if h.Interval == 0 {
+		h.Interval = DefaultHealthInterval
+	}
+	if h.Timeout == 0 {
+		h.Timeout = DefaultHealthTimeout
+	}
+	if h.FailureThreshold == 0 {
+		h.FailureThreshold = DefaultHealthFailureThreshold
+	}
+	if h.Type == HTTPCheck && h.Path == "" {
+		h.Path = "/"
+	}
+	return h
+}
+
+// Validate reports whether the check is well formed.
+func (h HealthCheck) Validate() error {
+	switch h.Type {
+	case HTTPCheck, TCPCheck:
+		port, err := parsePort(h.Port)
+		if err != nil {
+			return fmt.Errorf("%s health check: %v", h.Type, err)
+		}
+		if port.Proto() != "tcp" {
+			return fmt.Errorf("%s health check: port %s isn't a tcp port", h.Type, port)
+		}
+		if h.Type == HTTPCheck && h.Path != "" && !strings.HasPrefix(h.Path, "/") {
+			return fmt.Errorf("http health check: path %q doesn't start with /", h.Path)
+		}
+	case ExecCheck:
+		if len(h.Cmd) == 0 {
+			return errors.New("exec health check has no command")
+		}
+	default:
+		return fmt.Errorf("unknown health check type %q", h.Type)
+	}
+
+	if h.Interval < 0 || h.Timeout < 0 || h.FailureThreshold < 0 {
+		return errors.New("health check interval, timeout and failure threshold can't be negative")
+	}
+	return nil
+}
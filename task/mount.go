@@ -0,0 +1,95 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// MountType is the kind of storage a Mount gives a task.
+type MountType string
+
+const (
+	// Bind mounts a file or directory of the worker's host into the task.
+	Bind MountType = "bind"
+
+	// Volume mounts a named volume of the runtime into the task, creating the volume if it doesn't exist.
+	// Volumes outlive the tasks that use them.
+	Volume MountType = "volume"
+
+	// Tmpfs mounts a filesystem held in memory into the task; its content goes away with the task.
+	Tmpfs MountType = "tmpfs"
+)
+
+// LabelVolumeManaged is the label set on the volumes a runtime creates for tasks, marking them as the worker's to manage.
+const LabelVolumeManaged = "orchestra.volume.managed"
+
+var (
+	// ErrVolumeNotFound is returned by a VolumeManager asked about a volume it didn't create.
+	ErrVolumeNotFound = errors.New("volume not found")
+
+	// ErrVolumeInUse is returned by a VolumeManager asked to remove a volume that a container still uses.
+	ErrVolumeInUse = errors.New("volume in use")
+)
+
+// Mount is storage made available to a task at a path inside it.
+type Mount struct {
+	Type     MountType // Type is the kind of storage mounted.
+	Source   string    // Source is the host path of a Bind mount or the name of a Volume; Tmpfs mounts have none.
+	Target   string    // Target is the absolute path the storage is mounted at inside the task.
+	ReadOnly bool      // ReadOnly keeps the task from writing to the mount.
+	Size     int64     // Size limits a Tmpfs mount, in bytes; 0 leaves it to the runtime's default.
+}
+
+// Validate reports whether the mount is well formed.
+func (m Mount) Validate() error {
+	if !path.IsAbs(m.Target) {
+		return fmt.Errorf("mount target %q is not an absolute path", m.Target)
+	}
+
+	switch m.Type {
+	case Bind:
+		if !path.IsAbs(m.Source) {
+			return fmt.Errorf("bind mount at %s: source %q is not an absolute path", m.Target, m.Source)
+		}
+	case Volume:
+		if m.Source == "" || strings.ContainsAny(m.Source, `/\:`) {
+			return fmt.Errorf("volume mount at %s: invalid volume name %q", m.Target, m.Source)
+		}
+	case Tmpfs:
+		if m.Source != "" {
+			return fmt.Errorf("tmpfs mount at %s takes no source", m.Target)
+		}
+	default:
+		return fmt.Errorf("mount at %s: unknown type %q", m.Target, m.Type)
+	}
+
+	if m.Size != 0 && m.Type != Tmpfs {
+		return fmt.Errorf("%s mount at %s: only tmpfs mounts take a size", m.Type, m.Target)
+	}
+	if m.Size < 0 {
+		return fmt.Errorf("tmpfs mount at %s: size can't be negative", m.Target)
+	}
+	return nil
+}
+
+// VolumeInfo is what a VolumeManager reports about a volume it created for tasks.
+type VolumeInfo struct {
+	Name       string    // Name is the volume's name, which Volume mounts refer to it by.
+	Driver     string    // Driver is the volume driver that stores the volume, e.g. "local".
+	Mountpoint string    // Mountpoint is where the volume's data lives on the host.
+	CreatedAt  time.Time // CreatedAt is when the volume was created.
+}
+
+// VolumeManager is implemented by the runtimes that create named volumes for the Volume mounts of tasks.
+type VolumeManager interface {
+	// Volumes reports the volumes the runtime created for tasks, i.e. those carrying the LabelVolumeManaged label.
+	Volumes(ctx context.Context) ([]VolumeInfo, error)
+
+	// RemoveVolume removes a volume the runtime created for tasks. It returns ErrVolumeNotFound if there is no such
+	// volume and ErrVolumeInUse if a container still uses it.
+	RemoveVolume(ctx context.Context, name string) error
+}
@@ -0,0 +1,550 @@
+//go:build linux
+
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultProcessStateDir is where the Process runtime keeps the config, logs and state of its processes.
+	DefaultProcessStateDir = "/var/lib/orchestra/processes"
+
+	// DefaultCgroupRoot is the cgroup v2 directory under which every process gets a cgroup of its own.
+	DefaultCgroupRoot = "/sys/fs/cgroup/orchestra"
+
+	// cpuPeriod is the cgroup CPU period, in microseconds, against which a task's CPU quota is expressed.
+	cpuPeriod = 100000
+
+	// stopTimeout is how long a process is given to exit after SIGTERM before it's killed.
+	stopTimeout = 10 * time.Second
+)
+
+// Process is the Runtime that runs a task's Cmd as a process on the host, without a container image.
+// Each process is placed in its own cgroup v2 subtree under CgroupRoot, limited to the Memory and CPU
+// of its Config, and its stdout and stderr are captured to files under StateDir. Tasks with a Disk limit are
+// rejected, as the process writes straight to the host's filesystem.
+//
+// A process outlives the worker the same way a container does: its state is kept in StateDir,
+// so a restarted worker finds it through List.
+type Process struct {
+	StateDir   string // StateDir holds a directory per process with its state and logs.
+	CgroupRoot string // CgroupRoot is the cgroup v2 directory the processes' cgroups are created in.
+
+	mu    sync.Mutex
+	procs map[string]*process
+}
+
+// process is the state of a process, saved as JSON in its directory under StateDir.
+type process struct {
+	ID         string
+	Config     Config
+	Pid        int
+	State      string // State is "created", "running" or "exited", like a container's.
+	ExitCode   int
+	StartedAt  time.Time
+	FinishedAt time.Time
+
+	done chan struct{} // done is closed once the process has exited.
+}
+
+var _ Runtime = (*Process)(nil)
+
+// NewProcess creates a Process runtime and picks up the processes left in stateDir by an earlier run.
+// It fails if the processes can't be given cgroups of their own under cgroupRoot.
+func NewProcess(stateDir, cgroupRoot string) (*Process, error) {
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		return nil, fmt.Errorf("unable to create %s: %w", stateDir, err)
+	}
+	if err := setupCgroupRoot(cgroupRoot); err != nil {
+		return nil, err
+	}
+
+	p := &Process{
+		StateDir:   stateDir,
+		CgroupRoot: cgroupRoot,
+		procs:      make(map[string]*process),
+	}
+
+	entries, err := os.ReadDir(stateDir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", stateDir, err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		proc, err := p.load(e.Name())
+		if err != nil {
+			log.Printf("Error loading process %s: %v", e.Name(), err)
+			continue
+		}
+		p.procs[proc.ID] = proc
+		if proc.State == "running" {
+			// the process isn't a child of this worker anymore, so it can only be watched from afar.
+			go p.watch(proc)
+		}
+	}
+
+	return p, nil
+}
+
+// Pull checks that the executable named by image exists; processes have no image to download.
+// An empty image is accepted, the executable then being the first element of the Config's Cmd.
+func (p *Process) Pull(ctx context.Context, image string) error {
+	if image == "" {
+		return nil
+	}
+	if _, err := exec.LookPath(image); err != nil {
+		return fmt.Errorf("executable %s not found: %w", image, err)
+	}
+	return nil
+}
+
+func (p *Process) Create(ctx context.Context, config Config) (string, error) {
+	if len(config.Cmd) == 0 {
+		return "", errors.New("a process task needs a command to run")
+	}
+	if _, err := exec.LookPath(config.Cmd[0]); err != nil {
+		return "", fmt.Errorf("executable %s not found: %w", config.Cmd[0], err)
+	}
+
+	proc := &process{
+		ID:     "proc-" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+		Config: config,
+		State:  "created",
+		done:   make(chan struct{}),
+	}
+	if err := os.MkdirAll(p.dir(proc.ID), 0700); err != nil {
+		return "", fmt.Errorf("unable to create the directory of process %s: %w", proc.ID, err)
+	}
+
+	// the cgroup holds the whole process tree even without limits, so that Stop reaches every process.
+	if err := p.createCgroup(proc); err != nil {
+		os.RemoveAll(p.dir(proc.ID))
+		return "", err
+	}
+
+	if err := p.save(proc); err != nil {
+		os.RemoveAll(p.dir(proc.ID))
+		return "", err
+	}
+
+	p.mu.Lock()
+	p.procs[proc.ID] = proc
+	p.mu.Unlock()
+	return proc.ID, nil
+}
+
+func (p *Process) Start(ctx context.Context, id string) error {
+	proc, err := p.get(id)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	if proc.State != "created" {
+		p.mu.Unlock()
+		return fmt.Errorf("process %s has already been started", id)
+	}
+	p.mu.Unlock()
+
+	stdout, err := os.OpenFile(filepath.Join(p.dir(id), "stdout.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to open the stdout log of process %s: %w", id, err)
+	}
+	defer stdout.Close()
+	stderr, err := os.OpenFile(filepath.Join(p.dir(id), "stderr.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to open the stderr log of process %s: %w", id, err)
+	}
+	defer stderr.Close()
+
+	cmd := exec.Command(proc.Config.Cmd[0], proc.Config.Cmd[1:]...)
+	cmd.Env = processEnv(proc.Config.Env)
+	cmd.Dir = p.dir(id)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// a process group of its own lets Stop signal the whole tree, and the process isn't
+	// tied to the worker, so it keeps running across restarts of the worker like a container.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if cgroup := p.cgroup(id); dirExists(cgroup) {
+		fd, err := syscall.Open(cgroup, syscall.O_DIRECTORY|syscall.O_RDONLY, 0)
+		if err != nil {
+			return fmt.Errorf("unable to open cgroup %s: %w", cgroup, err)
+		}
+		defer syscall.Close(fd)
+		cmd.SysProcAttr.UseCgroupFD = true
+		cmd.SysProcAttr.CgroupFD = fd
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("unable to start process %s: %w", id, err)
+	}
+
+	p.mu.Lock()
+	proc.Pid = cmd.Process.Pid
+	proc.State = "running"
+	proc.StartedAt = time.Now().UTC()
+	err = p.save(proc)
+	p.mu.Unlock()
+	if err != nil {
+		log.Printf("Error saving the state of process %s: %v", id, err)
+	}
+
+	go func() {
+		cmd.Wait()
+		p.exited(proc, exitCode(cmd.ProcessState))
+	}()
+	return nil
+}
+
+func (p *Process) Stop(ctx context.Context, id string) error {
+	proc, err := p.get(id)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	running, pid := proc.State == "running", proc.Pid
+	p.mu.Unlock()
+	if !running {
+		return nil
+	}
+
+	if err := syscall.Kill(-pid, syscall.SIGTERM); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return fmt.Errorf("unable to signal process %s: %w", id, err)
+	}
+
+	select {
+	case <-proc.done:
+		return nil
+	case <-time.After(stopTimeout):
+	case <-ctx.Done():
+	}
+
+	if err := syscall.Kill(-pid, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return fmt.Errorf("unable to kill process %s: %w", id, err)
+	}
+	// children that left the process group are still in the cgroup.
+	if err := os.WriteFile(filepath.Join(p.cgroup(id), "cgroup.kill"), []byte("1"), 0644); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to kill the cgroup of process %s: %w", id, err)
+	}
+	select {
+	case <-proc.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Process) Remove(ctx context.Context, id string) error {
+	proc, err := p.get(id)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	running := proc.State == "running"
+	p.mu.Unlock()
+	if running {
+		return fmt.Errorf("cannot remove process %s: process is running", id)
+	}
+
+	if cgroup := p.cgroup(id); dirExists(cgroup) {
+		if err := os.Remove(cgroup); err != nil {
+			return fmt.Errorf("unable to remove cgroup %s: %w", cgroup, err)
+		}
+	}
+	if err := os.RemoveAll(p.dir(id)); err != nil {
+		return fmt.Errorf("unable to remove the directory of process %s: %w", id, err)
+	}
+
+	p.mu.Lock()
+	delete(p.procs, id)
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *Process) Inspect(ctx context.Context, id string) (ContainerInfo, error) {
+	proc, err := p.get(id)
+	if err != nil {
+		return ContainerInfo{}, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return proc.info(), nil
+}
+
+func (p *Process) List(ctx context.Context) ([]ContainerInfo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var infos []ContainerInfo
+	for _, proc := range p.procs {
+		if _, ok := proc.Config.Labels[LabelTaskID]; ok {
+			infos = append(infos, proc.info())
+		}
+	}
+	return infos, nil
+}
+
+func (p *Process) Logs(ctx context.Context, id string, stdout, stderr io.Writer) error {
+	if _, err := p.get(id); err != nil {
+		return err
+	}
+
+	for name, w := range map[string]io.Writer{"stdout.log": stdout, "stderr.log": stderr} {
+		f, err := os.Open(filepath.Join(p.dir(id), name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Process) Wait(ctx context.Context, id string) (int, error) {
+	proc, err := p.get(id)
+	if err != nil {
+		return 0, err
+	}
+
+	select {
+	case <-proc.done:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return proc.ExitCode, nil
+}
+
+func (p *Process) get(id string) (*process, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	proc, ok := p.procs[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return proc, nil
+}
+
+// exited records the exit of a process and releases everyone waiting for it.
+func (p *Process) exited(proc *process, code int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if proc.State == "exited" {
+		return
+	}
+	proc.State = "exited"
+	proc.ExitCode = code
+	proc.FinishedAt = time.Now().UTC()
+	if err := p.save(proc); err != nil {
+		log.Printf("Error saving the state of process %s: %v", proc.ID, err)
+	}
+	close(proc.done)
+}
+
+// watch polls a process that was started by an earlier run of the worker until it exits. The process is
+// looked for in its cgroup rather than signalled, so that another process reusing its pid isn't taken for it.
+// Its exit code can't be collected, so it's reported as -1.
+func (p *Process) watch(proc *process) {
+	for p.alive(proc) {
+		time.Sleep(time.Second)
+	}
+	p.exited(proc, -1)
+}
+
+// alive reports whether the process is still in its cgroup. It falls back to signalling the process when
+// the cgroup can't be read for another reason than being gone.
+func (p *Process) alive(proc *process) bool {
+	procs, err := os.ReadFile(filepath.Join(p.cgroup(proc.ID), "cgroup.procs"))
+	if errors.Is(err, os.ErrNotExist) {
+		return false
+	}
+	if err != nil {
+		log.Printf("Error reading the cgroup of process %s: %v", proc.ID, err)
+		return syscall.Kill(proc.Pid, 0) == nil
+	}
+
+	pid := strconv.Itoa(proc.Pid)
+	for _, p := range strings.Fields(string(procs)) {
+		if p == pid {
+			return true
+		}
+	}
+	return false
+}
+
+// createCgroup creates the cgroup of a process and writes the memory and CPU limits its Config sets, if any.
+func (p *Process) createCgroup(proc *process) error {
+	cgroup := p.cgroup(proc.ID)
+	if err := os.Mkdir(cgroup, 0755); err != nil {
+		return fmt.Errorf("unable to create cgroup %s: %w", cgroup, err)
+	}
+
+	limits := make(map[string]string)
+	if proc.Config.Memory > 0 {
+		limits["memory.max"] = strconv.FormatInt(proc.Config.Memory, 10)
+		limits["memory.swap.max"] = "0"
+	}
+	if proc.Config.CPU > 0 {
+		limits["cpu.max"] = fmt.Sprintf("%d %d", int(proc.Config.CPU*cpuPeriod), cpuPeriod)
+	}
+	for file, value := range limits {
+		err := os.WriteFile(filepath.Join(cgroup, file), []byte(value), 0644)
+		if err != nil && !(file == "memory.swap.max" && errors.Is(err, os.ErrNotExist)) {
+			os.Remove(cgroup)
+			return fmt.Errorf("unable to set %s of cgroup %s: %w", file, cgroup, err)
+		}
+	}
+	return nil
+}
+
+func (p *Process) dir(id string) string {
+	return filepath.Join(p.StateDir, id)
+}
+
+func (p *Process) cgroup(id string) string {
+	return filepath.Join(p.CgroupRoot, id)
+}
+
+func (p *Process) save(proc *process) error {
+	data, err := json.Marshal(proc)
+	if err != nil {
+		return fmt.Errorf("unable to marshal process %s: %w", proc.ID, err)
+	}
+	return os.WriteFile(filepath.Join(p.dir(proc.ID), "state.json"), data, 0600)
+}
+
+func (p *Process) load(id string) (*process, error) {
+	data, err := os.ReadFile(filepath.Join(p.dir(id), "state.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	proc := &process{done: make(chan struct{})}
+	if err := json.Unmarshal(data, proc); err != nil {
+		return nil, err
+	}
+	if proc.State == "exited" {
+		close(proc.done)
+	}
+	return proc, nil
+}
+
+// info describes the process the way a container is described. The caller must hold p.mu.
+func (proc *process) info() ContainerInfo {
+	return ContainerInfo{
+		ID:         proc.ID,
+		Image:      proc.Config.Image,
+		State:      proc.State,
+		ExitCode:   proc.ExitCode,
+		Labels:     proc.Config.Labels,
+		StartedAt:  proc.StartedAt,
+		FinishedAt: proc.FinishedAt,
+	}
+}
+
+// setupCgroupRoot prepares the cgroup v2 directory the processes' cgroups are created in, once, when the
+// runtime is created. cgroup v2 only lets a cgroup hand its controllers down to its children if it holds no
+// process itself, so root must be a cgroup the worker owns rather than, say, the one it runs in.
+func setupCgroupRoot(root string) error {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return fmt.Errorf("unable to create cgroup %s: %w", root, err)
+	}
+	if hasControllers(root) != nil {
+		if err := enableControllers(filepath.Dir(root)); err != nil {
+			return fmt.Errorf("the cpu and memory controllers aren't delegated to cgroup %s: %w", root, err)
+		}
+	}
+
+	procs, err := os.ReadFile(filepath.Join(root, "cgroup.procs"))
+	if err != nil {
+		return fmt.Errorf("unable to read the processes of cgroup %s: %w", root, err)
+	}
+	if len(strings.TrimSpace(string(procs))) > 0 {
+		return fmt.Errorf("cgroup %s holds processes of its own, so it can't limit the cgroups of its children", root)
+	}
+	return enableControllers(root)
+}
+
+// hasControllers checks that the cpu and memory controllers are available in a cgroup v2 directory.
+func hasControllers(cgroup string) error {
+	controllers, err := os.ReadFile(filepath.Join(cgroup, "cgroup.controllers"))
+	if err != nil {
+		return fmt.Errorf("cgroup v2 is not available at %s: %w", cgroup, err)
+	}
+	for _, c := range []string{"cpu", "memory"} {
+		if !strings.Contains(" "+strings.TrimSpace(string(controllers))+" ", " "+c+" ") {
+			return fmt.Errorf("the %s controller is not available in cgroup %s", c, cgroup)
+		}
+	}
+	return nil
+}
+
+// enableControllers makes the cpu and memory controllers available to the children of a cgroup v2 directory.
+func enableControllers(cgroup string) error {
+	if err := hasControllers(cgroup); err != nil {
+		return err
+	}
+
+	err := os.WriteFile(filepath.Join(cgroup, "cgroup.subtree_control"), []byte("+cpu +memory"), 0644)
+	if err != nil {
+		return fmt.Errorf("unable to enable the cpu and memory controllers in cgroup %s: %w", cgroup, err)
+	}
+	return nil
+}
+
+// exitCode reports the exit code of a process the way Docker reports a container's: 128 plus the
+// signal number when the process was killed by a signal.
+func exitCode(state *os.ProcessState) int {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return state.ExitCode()
+}
+
+// processEnv gives the process a PATH when its environment doesn't set one.
+func processEnv(env []string) []string {
+	for _, e := range env {
+		if strings.HasPrefix(e, "PATH=") {
+			return env
+		}
+	}
+	return append([]string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"}, env...)
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,164 @@
+//go:build linux
+
+package task
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newTestProcess creates a Process runtime with a cgroup of its own, skipping the test when cgroup v2
+// can't be written to, e.g. when the tests don't run as root.
+func newTestProcess(tb testing.TB, stateDir, cgroupRoot string) *Process {
+	tb.Helper()
+	p, err := NewProcess(stateDir, cgroupRoot)
+	if err != nil {
+		tb.Skipf("cgroup v2 isn't writable: %v", err)
+	}
+	return p
+}
+
+func testCgroupRoot(tb testing.TB) string {
+	tb.Helper()
+	root := filepath.Join("/sys/fs/cgroup", "orchestra-test-"+uuid.NewString())
+	tb.Cleanup(func() { os.Remove(root) })
+	return root
+}
+
+// runProcess creates and starts a process running cmd, and removes it at the end of the test.
+func runProcess(tb testing.TB, p *Process, config Config) string {
+	tb.Helper()
+	config.Labels = map[string]string{LabelTaskID: uuid.NewString()}
+	id, err := p.Create(context.Background(), config)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		p.Stop(context.Background(), id)
+		p.Remove(context.Background(), id)
+	})
+	if err := p.Start(context.Background(), id); err != nil {
+		tb.Fatal(err)
+	}
+	return id
+}
+
+func TestNewProcessWithoutCgroup(t *testing.T) {
+	_, err := NewProcess(t.TempDir(), t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "cgroup") {
+		t.Errorf("NewProcess() error = %v for a cgroup root that isn't a cgroup", err)
+	}
+}
+
+func TestProcessAlive(t *testing.T) {
+	p := &Process{CgroupRoot: t.TempDir()}
+	if err := os.Mkdir(p.cgroup("proc-1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(p.cgroup("proc-1"), "cgroup.procs"), []byte("12\n345\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id   string
+		pid  int
+		want bool
+	}{
+		{"proc-1", 345, true},
+		{"proc-1", 34, false}, // the pid was reused outside the cgroup.
+		{"proc-2", 345, false},
+	}
+	for _, tt := range tests {
+		if got := p.alive(&process{ID: tt.id, Pid: tt.pid}); got != tt.want {
+			t.Errorf("alive() of pid %d in %s = %v, want %v", tt.pid, tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestProcessRunsCommand(t *testing.T) {
+	p := newTestProcess(t, t.TempDir(), testCgroupRoot(t))
+	id := runProcess(t, p, Config{Cmd: []string{"sh", "-c", "echo hello; exit 3"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	code, err := p.Wait(ctx, id)
+	if err != nil || code != 3 {
+		t.Fatalf("Wait() = %d, %v, want 3", code, err)
+	}
+	info, err := p.Inspect(ctx, id)
+	if err != nil || info.State != "exited" || info.ExitCode != 3 {
+		t.Errorf("Inspect() = %+v, %v, want exited with 3", info, err)
+	}
+	var stdout, stderr bytes.Buffer
+	if err := p.Logs(ctx, id, &stdout, &stderr); err != nil || stdout.String() != "hello\n" {
+		t.Errorf("Logs() stdout = %q, %v, want %q", stdout.String(), err, "hello\n")
+	}
+}
+
+func TestProcessLimits(t *testing.T) {
+	p := newTestProcess(t, t.TempDir(), testCgroupRoot(t))
+	id := runProcess(t, p, Config{Cmd: []string{"sleep", "60"}, Memory: 64 << 20, CPU: 0.5})
+
+	for file, want := range map[string]string{"memory.max": "67108864", "cpu.max": "50000 100000"} {
+		got, err := os.ReadFile(filepath.Join(p.cgroup(id), file))
+		if err != nil || strings.TrimSpace(string(got)) != want {
+			t.Errorf("%s = %q, %v, want %q", file, got, err, want)
+		}
+	}
+	p.mu.Lock()
+	proc := p.procs[id]
+	p.mu.Unlock()
+	if !p.alive(proc) {
+		t.Errorf("process %d is not in its cgroup", proc.Pid)
+	}
+}
+
+func TestProcessStop(t *testing.T) {
+	p := newTestProcess(t, t.TempDir(), testCgroupRoot(t))
+	id := runProcess(t, p, Config{Cmd: []string{"sleep", "60"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Stop(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	// the process was killed by SIGTERM.
+	if code, err := p.Wait(ctx, id); err != nil || code != 143 {
+		t.Errorf("Wait() = %d, %v after Stop, want 143", code, err)
+	}
+	if err := p.Remove(ctx, id); err != nil {
+		t.Errorf("Remove() error = %v", err)
+	}
+	if dirExists(p.cgroup(id)) {
+		t.Error("the cgroup of the process was not removed")
+	}
+}
+
+// TestProcessWatchesProcessesOfEarlierRuns checks that a restarted worker notices when a process it
+// didn't start exits.
+func TestProcessWatchesProcessesOfEarlierRuns(t *testing.T) {
+	stateDir, cgroupRoot := t.TempDir(), testCgroupRoot(t)
+	first := newTestProcess(t, stateDir, cgroupRoot)
+	id := runProcess(t, first, Config{Cmd: []string{"sleep", "60"}})
+
+	second := newTestProcess(t, stateDir, cgroupRoot)
+	if info, err := second.Inspect(context.Background(), id); err != nil || info.State != "running" {
+		t.Fatalf("Inspect() = %+v, %v after a restart, want the process running", info, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := first.Stop(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if code, err := second.Wait(ctx, id); err != nil || code != -1 {
+		t.Errorf("Wait() = %d, %v, want -1 for a process started by an earlier run", code, err)
+	}
+}
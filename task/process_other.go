@@ -0,0 +1,24 @@
+//go:build !linux
+
+package task
+
+import "errors"
+
+const (
+	// DefaultProcessStateDir is where the Process runtime keeps the config, logs and state of its processes.
+	DefaultProcessStateDir = "/var/lib/orchestra/processes"
+
+	// DefaultCgroupRoot is the cgroup v2 directory under which every process gets a cgroup of its own.
+	DefaultCgroupRoot = "/sys/fs/cgroup/orchestra"
+)
+
+// Process is the Runtime that runs a task's Cmd as a process on the host. It relies on cgroup v2,
+// so it's only available on Linux.
+type Process struct {
+	Runtime
+}
+
+// NewProcess always fails outside of Linux.
+func NewProcess(stateDir, cgroupRoot string) (*Process, error) {
+	return nil, errors.New("the process runtime is only available on linux")
+}
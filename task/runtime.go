@@ -0,0 +1,133 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// ErrNotFound is returned by a Runtime when the container it's asked about doesn't exist.
+var ErrNotFound = errors.New("container not found")
+
+// Runtime is what the worker uses to run the containers of its tasks. Docker is the default implementation.
+type Runtime interface {
+	// Pull makes the image available to the runtime, the same way 'docker pull' does.
+	Pull(ctx context.Context, image string) error
+
+	// Create creates a container from the config without starting it and returns the container's ID.
+	Create(ctx context.Context, config Config) (string, error)
+
+	// Start starts a created container.
+	Start(ctx context.Context, id string) error
+
+	// Stop stops a running container.
+	Stop(ctx context.Context, id string) error
+
+	// Remove removes a stopped container.
+	Remove(ctx context.Context, id string) error
+
+	// Inspect reports the current state of a container, or ErrNotFound if it doesn't exist.
+	Inspect(ctx context.Context, id string) (ContainerInfo, error)
+
+	// List reports every container, running or not, that carries the LabelTaskID label.
+	List(ctx context.Context) ([]ContainerInfo, error)
+
+	// Logs copies what the container has written so far to stdout and stderr.
+	Logs(ctx context.Context, id string, stdout, stderr io.Writer) error
+
+	// Wait blocks until the container exits and returns its exit code.
+	Wait(ctx context.Context, id string) (int, error)
+}
+
+// ContainerInfo is what a Runtime reports about a container created for a task.
+type ContainerInfo struct {
+	ID         string            // ID is the container's ID.
+	Image      string            // Image is the image the container was created from.
+	State      string            // State is the runtime's state of the container, e.g. "running" or "exited".
+	ExitCode   int               // ExitCode is the exit code of the container's process once it has exited.
+	Labels     map[string]string // Labels are the labels set on the container.
+	StartedAt  time.Time         // StartedAt is the time at which the container last started.
+	FinishedAt time.Time         // FinishedAt is the time at which the container last exited.
+	IPAddress  string            // IPAddress is the container's address on its network; it's empty when the container uses the host's.
+}
+
+// Running reports whether the container's process is still running, or is about to run again because the
+// runtime is restarting the container according to its restart policy.
+func (c ContainerInfo) Running() bool {
+	return c.State == "running" || c.State == "restarting"
+}
+
+// Run this performs the same duty of 'docker run' on your command-line, using any Runtime.
+func Run(ctx context.Context, rt Runtime, config Config) DockerResult {
+	if err := rt.Pull(ctx, config.Image); err != nil {
+		log.Printf("Error pulling image %s: %v\n", config.Image, err)
+		return DockerResult{
+			Error:  err,
+			Action: PULL,
+			Result: FAILURE,
+		}
+	}
+
+	id, err := rt.Create(ctx, config)
+	if err != nil {
+		log.Printf("Error creating container %s: %v\n", config.Name, err)
+		return DockerResult{
+			Error:  err,
+			Action: CREATE,
+			Result: FAILURE,
+		}
+	}
+
+	if err = rt.Start(ctx, id); err != nil {
+		log.Printf("Error starting container %s: %v\n", config.Name, err)
+		return DockerResult{
+			Error:       err,
+			Action:      START,
+			ContainerId: id,
+			Result:      FAILURE,
+		}
+	}
+
+	// copy the logs of the container to the host stand output/error
+	if err = rt.Logs(ctx, id, os.Stdout, os.Stderr); err != nil {
+		log.Printf("Error getting container logs: %v\n", err)
+	}
+
+	return DockerResult{
+		ContainerId: id,
+		Action:      START,
+		Result:      SUCCESS,
+	}
+}
+
+// Stop performs the same function as both 'docker stop' and 'docker rm' commands, using any Runtime.
+func Stop(ctx context.Context, rt Runtime, id string) DockerResult {
+	if err := rt.Stop(ctx, id); err != nil && !errors.Is(err, ErrNotFound) {
+		log.Printf("Error stopping container %s: %v\n", id, err)
+		return DockerResult{
+			Error:       err,
+			Action:      STOP,
+			ContainerId: id,
+			Result:      FAILURE,
+		}
+	}
+
+	if err := rt.Remove(ctx, id); err != nil && !errors.Is(err, ErrNotFound) {
+		log.Printf("Error removing container %s: %v\n", id, err)
+		return DockerResult{
+			Error:       err,
+			Action:      REMOVE,
+			ContainerId: id,
+			Result:      FAILURE,
+		}
+	}
+
+	return DockerResult{
+		ContainerId: id,
+		Action:      REMOVE,
+		Result:      SUCCESS,
+	}
+}
@@ -0,0 +1,148 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/docker/go-connections/nat"
+)
+
+const (
+	// minCPU is the smallest CPU limit, in cores, Docker and the cgroup CPU controller accept.
+	minCPU = 0.01
+
+	// minDockerMemory is the smallest memory limit the Docker daemon accepts.
+	minDockerMemory = 6 << 20
+)
+
+// Validate reports whether the resources, ports, mounts, health check and restart policy of the task are well
+// formed and can be honoured by the runtime it asks for.
+func (t *Task) Validate() error {
+	if t.CPU < 0 || t.Memory < 0 || t.Disk < 0 {
+		return errors.New("CPU, Memory and Disk can't be negative")
+	}
+	if t.CPU > 0 && t.CPU < minCPU {
+		return fmt.Errorf("CPU of %g cores is below the %g cores a task can be limited to", t.CPU, minCPU)
+	}
+	isDocker := t.RuntimeName == "" || t.RuntimeName == "docker"
+	if isDocker && t.Memory > 0 && t.Memory < minDockerMemory {
+		return fmt.Errorf("memory limit of %d bytes is below the %d bytes Docker allows", t.Memory, minDockerMemory)
+	}
+	if t.RuntimeName == "wasm" && t.CPU > 0 {
+		return errors.New("the wasm runtime can't limit CPU")
+	}
+	if !isDocker && t.Disk > 0 {
+		return fmt.Errorf("the %s runtime can't limit disk", t.RuntimeName)
+	}
+
+	switch t.RestartPolicy {
+	case "", "always", "on-failure", "no", "never":
+	default:
+		return fmt.Errorf("unknown restart policy %q", t.RestartPolicy)
+	}
+
+	if len(t.ExposedPorts) > 0 || len(t.PortBindings) > 0 {
+		if t.RuntimeName != "" && t.RuntimeName != "docker" {
+			return fmt.Errorf("the %s runtime can't publish ports", t.RuntimeName)
+		}
+		if _, _, err := portBindings(t.ExposedPorts, t.PortBindings); err != nil {
+			return err
+		}
+	}
+
+	if len(t.Mounts) > 0 && t.RuntimeName != "" && t.RuntimeName != "docker" {
+		return fmt.Errorf("the %s runtime can't mount storage", t.RuntimeName)
+	}
+	targets := make(map[string]bool, len(t.Mounts))
+	for _, m := range t.Mounts {
+		if err := m.Validate(); err != nil {
+			return err
+		}
+		target := path.Clean(m.Target)
+		if targets[target] {
+			return fmt.Errorf("more than one mount at %s", target)
+		}
+		targets[target] = true
+	}
+
+	if h := t.HealthCheck; h != nil {
+		if err := h.Validate(); err != nil {
+			return err
+		}
+		switch {
+		case t.RuntimeName == "wasm":
+			return errors.New("the wasm runtime can't be health checked")
+		case t.RuntimeName == "process" && h.Type == ExecCheck:
+			return errors.New("the process runtime can't run exec health checks")
+		}
+	}
+	return nil
+}
+
+// portBindings returns the ports a container exposes and how they're published on the host, from the exposed
+// ports and the port bindings of a task. Bindings map a container port, e.g. "80/tcp" or "80", to a host port,
+// e.g. "8080" or "127.0.0.1:8080". Exposed ports without a binding are published on a port the host picks.
+func portBindings(exposed nat.PortSet, bindings map[string]string) (nat.PortSet, nat.PortMap, error) {
+	ports := make(nat.PortSet, len(exposed)+len(bindings))
+	published := make(nat.PortMap, len(exposed)+len(bindings))
+	for p := range exposed {
+		port, err := parsePort(string(p))
+		if err != nil {
+			return nil, nil, err
+		}
+		ports[port] = struct{}{}
+		published[port] = []nat.PortBinding{{}}
+	}
+
+	taken := make(map[string]nat.Port, len(bindings))
+	for _, p := range slices.Sorted(maps.Keys(bindings)) {
+		host := bindings[p]
+		port, err := parsePort(p)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ip, hostPort := "", host
+		if strings.Contains(host, ":") {
+			if ip, hostPort, err = net.SplitHostPort(host); err != nil {
+				return nil, nil, fmt.Errorf("invalid host address %q for port %s: %v", host, port, err)
+			}
+			if net.ParseIP(ip) == nil {
+				return nil, nil, fmt.Errorf("invalid host IP %q for port %s", ip, port)
+			}
+		}
+		if n, err := strconv.Atoi(hostPort); err != nil || n < 1 || n > 65535 {
+			return nil, nil, fmt.Errorf("invalid host port %q for port %s", hostPort, port)
+		}
+
+		key := net.JoinHostPort(ip, hostPort) + "/" + port.Proto()
+		if other, ok := taken[key]; ok {
+			return nil, nil, fmt.Errorf("ports %s and %s are both bound to host port %s", other, port, host)
+		}
+		taken[key] = port
+
+		ports[port] = struct{}{}
+		published[port] = []nat.PortBinding{{HostIP: ip, HostPort: hostPort}}
+	}
+	return ports, published, nil
+}
+
+// parsePort parses a container port such as "80/tcp", the protocol defaulting to tcp.
+func parsePort(p string) (nat.Port, error) {
+	proto, port := nat.SplitProtoPort(p)
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("invalid port %q", p)
+	}
+	switch proto {
+	case "tcp", "udp", "sctp":
+	default:
+		return "", fmt.Errorf("invalid protocol %q for port %q", proto, p)
+	}
+	return nat.NewPort(proto, port)
+}
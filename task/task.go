@@ -1,14 +1,6 @@
 package task
 
 import (
-	"context"
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/image"
-	"github.com/docker/docker/client"
-	"github.com/docker/docker/pkg/stdcopy"
-	"io"
-	"log"
-	"os"
 	"time"
 
 	"github.com/docker/go-connections/nat"
@@ -33,27 +25,65 @@ const (
 
 	// Failed indicates that a process or operation has been unsuccessful in completing its intended tasks.
 	Failed
+
+	// Lost indicates that the worker running the task is gone, so the manager no longer knows what became of it.
+	Lost
 )
 
-type Runtime struct {
+// ShouldRestart reports whether the task, having stopped in its current State, must be started again
+// to meet its DesiredState according to its RestartPolicy. Tasks with no policy are restarted on failure.
+func (t *Task) ShouldRestart() bool {
+	if t.DesiredState != Running {
+		return false
+	}
+
+	switch t.RestartPolicy {
+	case "no", "never":
+		return false
+	case "always":
+		return t.State == Completed || t.State == Failed
+	default:
+		return t.State == Failed
+	}
+}
+
+// RuntimeInfo holds what the worker learns about a task from the Runtime that runs it.
+type RuntimeInfo struct {
 	ContainerId string
 }
 
 // Task struct represents the metadata and properties associated with a specific task.
 type Task struct {
-	ID            uuid.UUID // ID represents the unique identifier for a Task.
-	Name          string    // Name is the human-readable identifier for
-	State         State     // State represents the current status of a Task within the system.
-	Image         string    // Image specifies the Docker image to be used for the task's container.
-	CPU           float64
-	Memory        int               // Memory is the amount of memory allocated to the task's container.
-	Disk          int               // Disk is the amount of disk space allocated to the task's container in gigabytes.
-	ExposedPorts  nat.PortSet       // ExposedPorts is a set of ports that are exposed by the task's container.
-	PortBindings  map[string]string // PortBindings maps container ports to host ports for network binding in the task's container.
-	RestartPolicy string            // RestartPolicy specifies the restart policy for the task's container, e.g., "always", "on-failure", or "never".
-	StartTime     time.Time         // StartTime is the timestamp indicating when the task started.
-	FinishTime    time.Time         // FinishTime is the timestamp indicating when the task finished.
-	Runtime       Runtime           // Runtime is used to encapsulate runtime-specific details for the task's container.
+	ID             uuid.UUID // ID represents the unique identifier for a Task.
+	Name           string    // Name is the human-readable identifier for
+	State          State     // State represents the current status of a Task within the system.
+	Image          string    // Image specifies the Docker image to be used for the task's container.
+	CPU            float64
+	Memory         int               // Memory is the amount of memory, in bytes, allocated to the task's container.
+	Disk           int               // Disk is the amount of disk space allocated to the task's container in gigabytes.
+	ExposedPorts   nat.PortSet       // ExposedPorts is a set of ports that are exposed by the task's container.
+	PortBindings   map[string]string // PortBindings maps container ports to host ports for network binding in the task's container.
+	RestartPolicy  string            // RestartPolicy specifies the restart policy for the task's container, e.g., "always", "on-failure", or "never".
+	StartTime      time.Time         // StartTime is the timestamp indicating when the task started.
+	FinishTime     time.Time         // FinishTime is the timestamp indicating when the task finished.
+	Runtime        RuntimeInfo       // Runtime is used to encapsulate runtime-specific details for the task's container.
+	DesiredState   State             // DesiredState is the state the manager wants the task in: Running until the user asks for it to be stopped, then Completed.
+	Cmd            []string          // Cmd is the command to run; for Docker tasks it overrides the image's default command.
+	Env            []string          // Env lists the environment variables of the task, as "KEY=value".
+	Constraints    []Constraint      // Constraints must all be met by the labels of the node the task is placed on.
+	Affinities     []Constraint      // Affinities are soft preferences for nodes whose labels meet them.
+	AntiAffinities []Constraint      // AntiAffinities are soft preferences against nodes whose labels meet them.
+	Tolerations    []Toleration      // Tolerations let the task be placed on nodes with matching taints.
+	Group          string            // Group names the gang the task belongs to: the members of a group are placed all at once or not at all.
+	GroupSize      int               // GroupSize is the number of members in the task's Group.
+	Priority       int               // Priority ranks the task against others: higher priority tasks are placed first and may preempt lower priority ones.
+	Strategy       string            // Strategy names the scheduler that places the task, e.g. "binpack" or "spread", overriding the manager's.
+	RuntimeName    string            // RuntimeName selects the Runtime that runs the task on the worker, e.g. "docker" (the default), "process" or "wasm".
+	Mounts         []Mount           // Mounts are the bind mounts, volumes and tmpfs mounts made available to the task.
+	HealthCheck    *HealthCheck      // HealthCheck tells the worker how to check that the running task works; tasks without one aren't checked.
+	Health         Health            // Health is what the HealthCheck last concluded about the task.
+	HealthHistory  []HealthResult    // HealthHistory holds the results of the last MaxHealthHistory checks, oldest first.
+	PendingReason  string            // PendingReason tells why the manager couldn't place the task when it last tried, e.g. "0/3 nodes available: 2 insufficient memory, 1 taint".
 }
 
 // TaskEvent represents an event that occurs within the lifecycle of a task.
@@ -62,23 +92,37 @@ type TaskEvent struct {
 	State     State     // State represents the current status of the task in the TaskEvent struct.
 	TimeStamp time.Time // TimeStamp is the time at which the TaskEvent occurred.
 	Task      Task      // Task represents the metadata and properties associated with a specific task.
+	Reason    string    // Reason explains why the manager recorded the event when it wasn't asked for, e.g. a preemption.
 }
 
 // Config represents the configuration settings for a container.
 type Config struct {
-	Name          string   // Name denotes the name of the container.
-	AttachStdin   bool     // AttachStdin specifies whether to attach the container's standard input.
-	AttachStdout  bool     // AttachStdout specifies whether to attach the container's standard output.
-	AttachStderr  bool     // AttachStderr specifies whether to attach the container's standard error.
-	Cmd           []string // Cmd specifies the command to run in the container.
-	Image         string   // Image denotes the container image to use.
-	Memory        int64    // Memory specifies the memory limit (in bytes) for the container.
-	Disk          int64    // Disk specifies the disk space limit (in bytes) for the container.
-	Env           []string // Env lists the environment variables for the container.
-	RestartPolicy string   // RestartPolicy defines the restart policy for the container.
-	Runtime       Runtime
+	Name          string            // Name denotes the name of the container.
+	AttachStdin   bool              // AttachStdin specifies whether to attach the container's standard input.
+	AttachStdout  bool              // AttachStdout specifies whether to attach the container's standard output.
+	AttachStderr  bool              // AttachStderr specifies whether to attach the container's standard error.
+	Cmd           []string          // Cmd specifies the command to run in the container.
+	Image         string            // Image denotes the container image to use.
+	Memory        int64             // Memory specifies the memory limit (in bytes) for the container.
+	CPU           float64           // CPU specifies the number of CPU cores the container may use.
+	Disk          int64             // Disk specifies the disk space limit (in bytes) for the container.
+	Env           []string          // Env lists the environment variables for the container.
+	RestartPolicy string            // RestartPolicy is the task's restart policy, which the Docker daemon applies to the container.
+	ExposedPorts  nat.PortSet       // ExposedPorts are the ports the container exposes, each published on a port the host picks unless it's bound in PortBindings.
+	Mounts        []Mount           // Mounts are the storage mounted into the container.
+	PortBindings  map[string]string // PortBindings maps container ports, e.g. "80/tcp", to the host ports they're published on, e.g. "8080" or "127.0.0.1:8080".
+	Labels        map[string]string // Labels are attached to the container so it can be traced back to its task.
+	Runtime       RuntimeInfo
 }
 
+const (
+	// LabelTaskID is the container label holding the ID of the task the container runs.
+	LabelTaskID = "orchestra.task.id"
+
+	// LabelTaskName is the container label holding the name of the task the container runs.
+	LabelTaskName = "orchestra.task.name"
+)
+
 type DockerAction string
 
 const (
@@ -106,7 +150,7 @@ const (
 	FAILURE DockerResultMessage = "Failure"
 )
 
-// DockerResult captures the outcome of a Docker operation, including error details, action type, container ID, and result message.
+// DockerResult captures the outcome of a Runtime operation, including error details, action type, container ID, and result message.
 type DockerResult struct {
 	Error       error
 	Action      DockerAction
@@ -114,12 +158,6 @@ type DockerResult struct {
 	Result      DockerResultMessage
 }
 
-type Docker struct {
-	Client      *client.Client
-	Config      Config
-	ContainerId string
-}
-
 var stateTransitionMap = map[State][]State{
 	Pending: {
 		Running,
@@ -134,6 +172,13 @@ var stateTransitionMap = map[State][]State{
 		Completed,
 		Failed,
 	},
+	// a finished task may be scheduled again when the manager restarts it.
+	Completed: {
+		Scheduled,
+	},
+	Failed: {
+		Scheduled,
+	},
 }
 
 func Contains(states []State, state State) bool {
@@ -149,120 +194,26 @@ func ValidateStateTransition(from, to State) bool {
 	return Contains(stateTransitionMap[from], to)
 }
 
-// Run this performs the same duty of 'docker run' on your command-line.
-func (d *Docker) Run() DockerResult {
-	ctx := context.Background()
-	rc, err := d.Client.ImagePull(ctx, d.Config.Image, image.PullOptions{})
-	if err != nil {
-		log.Printf("Error pulling image %s: %v\n", d.Config.Name, d.Config)
-		return DockerResult{
-			Error:  err,
-			Action: PULL,
-		}
-	}
-
-	// copy the reader to the standard output of the container.
-	_, err = io.Copy(os.Stdout, rc)
-	if err != nil {
-		return DockerResult{}
-	}
-
-	rp := container.RestartPolicy{
-		Name: container.RestartPolicyMode(d.Config.RestartPolicy),
-	}
-	r := container.Resources{
-		Memory: d.Config.Memory,
-	}
-	cc := container.Config{
-		Image: d.Config.Image,
-		Env:   d.Config.Env,
-	}
-	hc := container.HostConfig{
-		RestartPolicy:   rp,
-		Resources:       r,
-		PublishAllPorts: true,
-	}
-
-	resp, err := d.Client.ContainerCreate(ctx, &cc, &hc, nil, nil, d.Config.Name)
-	if err != nil {
-		log.Printf("Error creating container %s: %v\n", d.Config.Name, err)
-		return DockerResult{
-			Error:  err,
-			Action: CREATE,
-		}
-	}
-
-	if err = d.Client.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
-		log.Printf("Error starting container %s: %v\n", d.Config.Name, err)
-		return DockerResult{
-			Error:  err,
-			Action: START,
-		}
-	}
-
-	// track the containerID.
-	d.Config.Runtime.ContainerId = resp.ID
-	d.ContainerId = resp.ID
-
-	out, err := d.Client.ContainerLogs(ctx, resp.ID, container.LogsOptions{
-		ShowStdout: true,
-		ShowStderr: true,
-	})
-	if err != nil {
-		log.Printf("Error getting container logs: %v\n", err)
-		return DockerResult{
-			Error: err,
-		}
-	}
-
-	// copy the logs of the container to the host stand output/error
-	if _, err = stdcopy.StdCopy(os.Stdout, os.Stderr, out); err != nil {
-		log.Printf("Error copying container logs: %v\n", err)
-		return DockerResult{}
-	}
-
-	return DockerResult{
-		ContainerId: resp.ID,
-		Action:      START,
-		Result:      SUCCESS,
-	}
-}
-
-// Stop performs the same function as both 'docker stop' and 'docker rm' commands.
-func (d *Docker) Stop(containerId string) DockerResult {
-	log.Printf("Stopping container %s\n", d.Config.Name)
-	ctx := context.Background()
-	if err := d.Client.ContainerStop(ctx, containerId, container.StopOptions{}); err != nil {
-		log.Printf("Error stopping container %s: %v\n", d.Config.Name, err)
-		panic(err)
-	}
-
-	if err := d.Client.ContainerRemove(ctx, containerId, container.RemoveOptions{}); err != nil {
-		log.Printf("Error removing container %s: %v\n", d.Config.Name, err)
-		panic(err)
-	}
-
-	return DockerResult{
-		ContainerId: d.ContainerId,
-		Action:      REMOVE,
-		Result:      SUCCESS,
-	}
-}
-
 func NewConfig(t *Task) Config {
 	return Config{
-		Name:  t.Name,
-		Image: t.Image,
-		Runtime: Runtime{
+		Name:   t.Name,
+		Image:  t.Image,
+		Cmd:    t.Cmd,
+		Env:    t.Env,
+		Memory: int64(t.Memory),
+		CPU:    t.CPU,
+		// a task's Disk is in gigabytes.
+		Disk:          int64(t.Disk) << 30,
+		RestartPolicy: t.RestartPolicy,
+		ExposedPorts:  t.ExposedPorts,
+		PortBindings:  t.PortBindings,
+		Mounts:        t.Mounts,
+		Labels: map[string]string{
+			LabelTaskID:   t.ID.String(),
+			LabelTaskName: t.Name,
+		},
+		Runtime: RuntimeInfo{
 			ContainerId: t.Runtime.ContainerId,
 		},
 	}
 }
-
-func NewDocker(config Config) Docker {
-	clientWithOpts, _ := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	return Docker{
-		Client: clientWithOpts,
-		Config: config,
-	}
-}
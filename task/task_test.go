@@ -0,0 +1,113 @@
+package task
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateStateTransition(t *testing.T) {
+	tests := []struct {
+		from, to State
+		valid    bool
+	}{
+		{Pending, Running, true},
+		{Scheduled, Scheduled, true},
+		{Scheduled, Running, true},
+		{Scheduled, Failed, true},
+		{Running, Running, true},
+		{Running, Completed, true},
+		{Running, Failed, true},
+		{Completed, Scheduled, true},
+		{Failed, Scheduled, true},
+
+		{Pending, Completed, false},
+		{Pending, Scheduled, false},
+		{Scheduled, Completed, false},
+		{Running, Scheduled, false},
+		{Running, Pending, false},
+		{Completed, Running, false},
+		{Completed, Completed, false},
+		{Failed, Running, false},
+		{Failed, Completed, false},
+		{Lost, Running, false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidateStateTransition(tt.from, tt.to); got != tt.valid {
+			t.Errorf("ValidateStateTransition(%v, %v) = %v, want %v", tt.from, tt.to, got, tt.valid)
+		}
+	}
+}
+
+func TestShouldRestart(t *testing.T) {
+	tests := []struct {
+		policy string
+		state  State
+		want   bool
+	}{
+		{"", Completed, false},
+		{"", Failed, true},
+		{"", Running, false},
+		{"always", Completed, true},
+		{"on-failure", Failed, true},
+		{"on-failure", Completed, false},
+		{"no", Failed, false},
+		{"never", Completed, false},
+	}
+
+	for _, tt := range tests {
+		task := Task{RestartPolicy: tt.policy, State: tt.state, DesiredState: Running}
+		if got := task.ShouldRestart(); got != tt.want {
+			t.Errorf("ShouldRestart() with policy %q in state %v = %v, want %v", tt.policy, tt.state, got, tt.want)
+		}
+	}
+
+	stopped := Task{State: Failed, DesiredState: Completed}
+	if stopped.ShouldRestart() {
+		t.Error("ShouldRestart() = true for a task that was asked to stop")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		task Task
+		err  string // err is a substring of the expected error, or empty if the task is valid.
+	}{
+		{"empty task", Task{}, ""},
+		{"limits", Task{CPU: 0.5, Memory: 64 << 20, Disk: 1}, ""},
+		{"negative memory", Task{Memory: -1}, "can't be negative"},
+		{"tiny CPU", Task{CPU: 0.001}, "below the 0.01 cores"},
+		{"tiny Docker memory", Task{Memory: 1 << 20}, "below the 6291456 bytes Docker allows"},
+		{"tiny process memory", Task{Memory: 1 << 20, RuntimeName: "process"}, ""},
+		{"process limits", Task{CPU: 0.5, Memory: 64 << 20, RuntimeName: "process"}, ""},
+		{"disk on process", Task{Disk: 1, RuntimeName: "process"}, "can't limit disk"},
+		{"wasm memory", Task{Memory: 64 << 20, RuntimeName: "wasm"}, ""},
+		{"CPU on wasm", Task{CPU: 0.5, RuntimeName: "wasm"}, "can't limit CPU"},
+		{"disk on wasm", Task{Disk: 1, RuntimeName: "wasm"}, "can't limit disk"},
+		{"unknown restart policy", Task{RestartPolicy: "sometimes"}, "unknown restart policy"},
+		{"port binding", Task{PortBindings: map[string]string{"80/tcp": "127.0.0.1:8080"}}, ""},
+		{"invalid port", Task{PortBindings: map[string]string{"http": "8080"}}, "invalid port"},
+		{"host port taken twice", Task{PortBindings: map[string]string{"80": "8080", "81": "8080"}}, "both bound to host port"},
+		{"ports on process", Task{RuntimeName: "process", PortBindings: map[string]string{"80": "8080"}}, "can't publish ports"},
+		{"mount", Task{Mounts: []Mount{{Type: Volume, Source: "data", Target: "/data"}}}, ""},
+		{"mounts on one target", Task{Mounts: []Mount{{Type: Tmpfs, Target: "/tmp"}, {Type: Volume, Source: "tmp", Target: "/tmp/"}}}, "more than one mount"},
+		{"health check", Task{HealthCheck: &HealthCheck{Type: HTTPCheck, Port: "8080"}}, ""},
+		{"udp health check", Task{HealthCheck: &HealthCheck{Type: TCPCheck, Port: "53/udp"}}, "isn't a tcp port"},
+		{"exec check on process", Task{RuntimeName: "process", HealthCheck: &HealthCheck{Type: ExecCheck, Cmd: []string{"true"}}}, "can't run exec health checks"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.task.Validate()
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("Validate() = %v, want no error", err)
+			case tt.err != "" && err == nil:
+				t.Errorf("Validate() = nil, want an error containing %q", tt.err)
+			case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"orchestra/node"
+)
+
+// Toleration lets a task be placed on, and stay on, nodes carrying the taints it matches.
+type Toleration struct {
+	Key      string           // Key is the key of the taints tolerated; with Exists, an empty Key tolerates every taint.
+	Operator Operator         // Operator is Equals, to tolerate the taints with the given Value, or Exists, to tolerate any value.
+	Value    string           // Value is the value of the taints tolerated with Equals.
+	Effect   node.TaintEffect // Effect is the effect of the taints tolerated; empty tolerates every effect.
+}
+
+// Validate reports whether the toleration is well formed.
+func (t Toleration) Validate() error {
+	switch t.Operator {
+	case Equals:
+		if t.Key == "" {
+			return errors.New("toleration with equals has no key")
+		}
+	case Exists:
+		if t.Value != "" {
+			return fmt.Errorf("toleration of %q: %s takes no value", t.Key, t.Operator)
+		}
+	default:
+		return fmt.Errorf("toleration of %q: unknown operator %q", t.Key, t.Operator)
+	}
+
+	switch t.Effect {
+	case "", node.NoSchedule, node.PreferNoSchedule, node.NoExecute:
+		return nil
+	default:
+		return fmt.Errorf("toleration of %q: unknown effect %q", t.Key, t.Effect)
+	}
+}
+
+// Tolerates reports whether the toleration matches the taint.
+func (t Toleration) Tolerates(taint node.Taint) bool {
+	if t.Effect != "" && t.Effect != taint.Effect {
+		return false
+	}
+	if t.Operator == Exists {
+		return t.Key == "" || t.Key == taint.Key
+	}
+	return t.Key == taint.Key && t.Value == taint.Value
+}
+
+// Tolerates reports whether any of the task's tolerations matches the taint.
+func (t *Task) Tolerates(taint node.Taint) bool {
+	for _, tol := range t.Tolerations {
+		if tol.Tolerates(taint) {
+			return true
+		}
+	}
+	return false
+}
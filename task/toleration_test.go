@@ -0,0 +1,62 @@
+package task
+
+import (
+	"orchestra/node"
+	"testing"
+)
+
+func TestTolerationTolerates(t *testing.T) {
+	taint := node.Taint{Key: "dedicated", Value: "infra", Effect: node.NoExecute}
+
+	tests := []struct {
+		tol  Toleration
+		want bool
+	}{
+		{Toleration{Key: "dedicated", Operator: Equals, Value: "infra"}, true},
+		{Toleration{Key: "dedicated", Operator: Equals, Value: "web"}, false},
+		{Toleration{Key: "gpu", Operator: Equals, Value: "infra"}, false},
+		{Toleration{Key: "dedicated", Operator: Exists}, true},
+		{Toleration{Key: "gpu", Operator: Exists}, false},
+		{Toleration{Operator: Exists}, true}, // an empty key tolerates every taint.
+		{Toleration{Key: "dedicated", Operator: Exists, Effect: node.NoExecute}, true},
+		{Toleration{Key: "dedicated", Operator: Exists, Effect: node.NoSchedule}, false},
+		{Toleration{Operator: Exists, Effect: node.PreferNoSchedule}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.tol.Tolerates(taint); got != tt.want {
+			t.Errorf("%+v: Tolerates(%s) = %v, want %v", tt.tol, taint, got, tt.want)
+		}
+	}
+}
+
+func TestTaskTolerates(t *testing.T) {
+	taint := node.Taint{Key: "dedicated", Value: "infra", Effect: node.NoSchedule}
+
+	if (&Task{}).Tolerates(taint) {
+		t.Error("a task without tolerations tolerates a taint")
+	}
+	tk := Task{Tolerations: []Toleration{{Key: "gpu", Operator: Exists}, {Key: "dedicated", Operator: Equals, Value: "infra"}}}
+	if !tk.Tolerates(taint) {
+		t.Error("a task with a matching toleration doesn't tolerate the taint")
+	}
+}
+
+func TestTolerationValidate(t *testing.T) {
+	tests := []struct {
+		tol   Toleration
+		valid bool
+	}{
+		{Toleration{Key: "dedicated", Operator: Equals, Value: "infra"}, true},
+		{Toleration{Operator: Equals, Value: "infra"}, false},
+		{Toleration{Operator: Exists}, true},
+		{Toleration{Key: "dedicated", Operator: Exists, Value: "infra"}, false},
+		{Toleration{Key: "dedicated", Operator: In, Value: "infra"}, false},
+		{Toleration{Key: "dedicated", Operator: Exists, Effect: node.NoExecute}, true},
+		{Toleration{Key: "dedicated", Operator: Exists, Effect: "Evict"}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.tol.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%+v: Validate() error = %v, want valid %v", tt.tol, err, tt.valid)
+		}
+	}
+}
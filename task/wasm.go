@@ -0,0 +1,519 @@
+package task
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+const (
+	// DefaultWasmStateDir is where the Wasm runtime keeps the downloaded modules and the logs and state of its instances.
+	DefaultWasmStateDir = "/var/lib/orchestra/wasm"
+
+	// wasmPageSize is the size of a WebAssembly memory page, the unit in which memory limits are set.
+	wasmPageSize = 65536
+
+	// wasmMaxPages is the most pages a 32-bit WebAssembly memory can have (4 GiB).
+	wasmMaxPages = 65536
+)
+
+// Wasm is the Runtime that runs WASI modules in-process with wazero, so tasks can run on nodes without a
+// Docker daemon. The Image of a task is the module to run: a path on the worker or an http(s) URL it's
+// downloaded from. The Cmd of the task is passed to the module as its arguments and its Env as its
+// environment, and the module's memory is limited to the task's Memory. Tasks with a CPU or Disk
+// limit are rejected, as neither can be enforced.
+//
+// Instances run inside the worker, so unlike containers they don't outlive it: instances that were running
+// when the worker stopped are reported as exited with the code -1 once it's back.
+type Wasm struct {
+	StateDir string       // StateDir holds the downloaded modules and a directory per instance with its state and logs.
+	Client   *http.Client // Client downloads the modules given by URL.
+
+	cache     wazero.CompilationCache
+	mu        sync.Mutex
+	instances map[string]*wasmInstance
+}
+
+// wasmInstance is the state of a module instance, saved as JSON in its directory under StateDir.
+type wasmInstance struct {
+	ID         string
+	Config     Config
+	Module     string // Module is the path of the .wasm file the instance runs.
+	State      string // State is "created", "running" or "exited", like a container's.
+	ExitCode   int
+	StartedAt  time.Time
+	FinishedAt time.Time
+
+	cancel context.CancelFunc // cancel ends the instance while it's running.
+	done   chan struct{}      // done is closed once the instance has exited.
+}
+
+var _ Runtime = (*Wasm)(nil)
+
+// NewWasm creates a Wasm runtime and picks up the instances left in stateDir by an earlier run.
+func NewWasm(stateDir string) (*Wasm, error) {
+	if err := os.MkdirAll(filepath.Join(stateDir, "modules"), 0700); err != nil {
+		return nil, fmt.Errorf("unable to create %s: %w", stateDir, err)
+	}
+
+	w := &Wasm{
+		StateDir:  stateDir,
+		Client:    http.DefaultClient,
+		cache:     wazero.NewCompilationCache(),
+		instances: make(map[string]*wasmInstance),
+	}
+
+	entries, err := os.ReadDir(filepath.Join(stateDir, "instances"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("unable to read %s: %w", stateDir, err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		inst, err := w.load(e.Name())
+		if err != nil {
+			log.Printf("Error loading wasm instance %s: %v", e.Name(), err)
+			continue
+		}
+		w.instances[inst.ID] = inst
+		if inst.State == "running" {
+			// the instance ran inside the previous worker and died with it.
+			w.exited(inst, -1)
+		}
+	}
+
+	return w, nil
+}
+
+// Pull makes the module available on the worker: a module given by URL is downloaded into StateDir,
+// unless it already was, and a module given by path must exist. Either way the module is compiled,
+// so that an invalid module fails the task before it's created.
+func (w *Wasm) Pull(ctx context.Context, image string) error {
+	path, err := w.modulePath(image)
+	if err != nil {
+		return err
+	}
+
+	if isURL(image) {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			if err := w.download(ctx, image, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	wasm, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read module %s: %w", image, err)
+	}
+	rt := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCompilationCache(w.cache))
+	defer rt.Close(ctx)
+	if _, err := rt.CompileModule(ctx, wasm); err != nil {
+		return fmt.Errorf("invalid module %s: %w", image, err)
+	}
+	return nil
+}
+
+func (w *Wasm) Create(ctx context.Context, config Config) (string, error) {
+	path, err := w.modulePath(config.Image)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("module %s not found: %w", config.Image, err)
+	}
+
+	inst := &wasmInstance{
+		ID:     "wasm-" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+		Config: config,
+		Module: path,
+		State:  "created",
+		done:   make(chan struct{}),
+	}
+	if err := os.MkdirAll(w.dir(inst.ID), 0700); err != nil {
+		return "", fmt.Errorf("unable to create the directory of wasm instance %s: %w", inst.ID, err)
+	}
+	if err := w.save(inst); err != nil {
+		os.RemoveAll(w.dir(inst.ID))
+		return "", err
+	}
+
+	w.mu.Lock()
+	w.instances[inst.ID] = inst
+	w.mu.Unlock()
+	return inst.ID, nil
+}
+
+func (w *Wasm) Start(ctx context.Context, id string) error {
+	inst, err := w.get(id)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	if inst.State != "created" {
+		w.mu.Unlock()
+		return fmt.Errorf("wasm instance %s has already been started", id)
+	}
+	w.mu.Unlock()
+
+	wasm, err := os.ReadFile(inst.Module)
+	if err != nil {
+		return fmt.Errorf("unable to read module %s: %w", inst.Config.Image, err)
+	}
+
+	stdout, err := os.OpenFile(filepath.Join(w.dir(id), "stdout.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to open the stdout log of wasm instance %s: %w", id, err)
+	}
+	stderr, err := os.OpenFile(filepath.Join(w.dir(id), "stderr.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		stdout.Close()
+		return fmt.Errorf("unable to open the stderr log of wasm instance %s: %w", id, err)
+	}
+
+	// the instance must not end with the request that started it, only when it's stopped.
+	runCtx, cancel := context.WithCancel(context.Background())
+	runtimeConfig := wazero.NewRuntimeConfig().
+		WithCompilationCache(w.cache).
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(memoryPages(inst.Config.Memory))
+	rt := wazero.NewRuntimeWithConfig(runCtx, runtimeConfig)
+
+	closeAll := func() {
+		rt.Close(context.Background())
+		cancel()
+		stdout.Close()
+		stderr.Close()
+	}
+
+	if _, err := wasi_snapshot_preview1.Instantiate(runCtx, rt); err != nil {
+		closeAll()
+		return fmt.Errorf("unable to set up WASI for wasm instance %s: %w", id, err)
+	}
+	compiled, err := rt.CompileModule(runCtx, wasm)
+	if err != nil {
+		closeAll()
+		return fmt.Errorf("invalid module %s: %w", inst.Config.Image, err)
+	}
+
+	moduleConfig := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs(append([]string{filepath.Base(inst.Module)}, inst.Config.Cmd...)...).
+		WithStdout(stdout).
+		WithStderr(stderr).
+		WithSysWalltime().
+		WithSysNanotime().
+		// the default nanosleep can't be interrupted, which would keep a sleeping module from being stopped.
+		WithNanosleep(func(ns int64) {
+			timer := time.NewTimer(time.Duration(ns))
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-runCtx.Done():
+			}
+		}).
+		WithRandSource(rand.Reader)
+	for _, e := range inst.Config.Env {
+		key, value, _ := strings.Cut(e, "=")
+		moduleConfig = moduleConfig.WithEnv(key, value)
+	}
+
+	w.mu.Lock()
+	inst.State = "running"
+	inst.StartedAt = time.Now().UTC()
+	inst.cancel = cancel
+	err = w.save(inst)
+	w.mu.Unlock()
+	if err != nil {
+		log.Printf("Error saving the state of wasm instance %s: %v", id, err)
+	}
+
+	go func() {
+		defer closeAll()
+		mod, err := rt.InstantiateModule(runCtx, compiled, moduleConfig)
+		if mod != nil {
+			mod.Close(runCtx)
+		}
+		var exitErr *sys.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			// the module trapped, and the reason would otherwise be lost.
+			fmt.Fprintf(stderr, "%v\n", err)
+		}
+		w.exited(inst, wasmExitCode(err))
+	}()
+	return nil
+}
+
+func (w *Wasm) Stop(ctx context.Context, id string) error {
+	inst, err := w.get(id)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	running, cancel := inst.State == "running", inst.cancel
+	w.mu.Unlock()
+	if !running {
+		return nil
+	}
+
+	cancel()
+	select {
+	case <-inst.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *Wasm) Remove(ctx context.Context, id string) error {
+	inst, err := w.get(id)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	running := inst.State == "running"
+	w.mu.Unlock()
+	if running {
+		return fmt.Errorf("cannot remove wasm instance %s: instance is running", id)
+	}
+
+	if err := os.RemoveAll(w.dir(id)); err != nil {
+		return fmt.Errorf("unable to remove the directory of wasm instance %s: %w", id, err)
+	}
+
+	w.mu.Lock()
+	delete(w.instances, id)
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *Wasm) Inspect(ctx context.Context, id string) (ContainerInfo, error) {
+	inst, err := w.get(id)
+	if err != nil {
+		return ContainerInfo{}, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return inst.info(), nil
+}
+
+func (w *Wasm) List(ctx context.Context) ([]ContainerInfo, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var infos []ContainerInfo
+	for _, inst := range w.instances {
+		if _, ok := inst.Config.Labels[LabelTaskID]; ok {
+			infos = append(infos, inst.info())
+		}
+	}
+	return infos, nil
+}
+
+func (w *Wasm) Logs(ctx context.Context, id string, stdout, stderr io.Writer) error {
+	if _, err := w.get(id); err != nil {
+		return err
+	}
+
+	for name, out := range map[string]io.Writer{"stdout.log": stdout, "stderr.log": stderr} {
+		f, err := os.Open(filepath.Join(w.dir(id), name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Wasm) Wait(ctx context.Context, id string) (int, error) {
+	inst, err := w.get(id)
+	if err != nil {
+		return 0, err
+	}
+
+	select {
+	case <-inst.done:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return inst.ExitCode, nil
+}
+
+func (w *Wasm) get(id string) (*wasmInstance, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	inst, ok := w.instances[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return inst, nil
+}
+
+// exited records the exit of an instance and releases everyone waiting for it.
+func (w *Wasm) exited(inst *wasmInstance, code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if inst.State == "exited" {
+		return
+	}
+	inst.State = "exited"
+	inst.ExitCode = code
+	inst.FinishedAt = time.Now().UTC()
+	inst.cancel = nil
+	if err := w.save(inst); err != nil {
+		log.Printf("Error saving the state of wasm instance %s: %v", inst.ID, err)
+	}
+	close(inst.done)
+}
+
+// modulePath returns where the module named by image is on the worker; modules given by URL
+// are kept under StateDir, named after the URL.
+func (w *Wasm) modulePath(image string) (string, error) {
+	if image == "" {
+		return "", errors.New("a wasm task needs the path or the URL of its module as its image")
+	}
+	if isURL(image) {
+		sum := sha256.Sum256([]byte(image))
+		return filepath.Join(w.StateDir, "modules", hex.EncodeToString(sum[:])+".wasm"), nil
+	}
+	return image, nil
+}
+
+// download fetches a module into path, going through a temporary file so that an interrupted
+// download isn't mistaken for the module.
+func (w *Wasm) download(ctx context.Context, url, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("unable to download module %s: %w", url, err)
+	}
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to download module %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to download module %s: unexpected status %s", url, resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "download-*")
+	if err != nil {
+		return fmt.Errorf("unable to download module %s: %w", url, err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to download module %s: %w", url, err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (w *Wasm) dir(id string) string {
+	return filepath.Join(w.StateDir, "instances", id)
+}
+
+func (w *Wasm) save(inst *wasmInstance) error {
+	data, err := json.Marshal(inst)
+	if err != nil {
+		return fmt.Errorf("unable to marshal wasm instance %s: %w", inst.ID, err)
+	}
+	return os.WriteFile(filepath.Join(w.dir(inst.ID), "state.json"), data, 0600)
+}
+
+func (w *Wasm) load(id string) (*wasmInstance, error) {
+	data, err := os.ReadFile(filepath.Join(w.dir(id), "state.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	inst := &wasmInstance{done: make(chan struct{})}
+	if err := json.Unmarshal(data, inst); err != nil {
+		return nil, err
+	}
+	if inst.State == "exited" {
+		close(inst.done)
+	}
+	return inst, nil
+}
+
+// info describes the instance the way a container is described. The caller must hold w.mu.
+func (inst *wasmInstance) info() ContainerInfo {
+	return ContainerInfo{
+		ID:         inst.ID,
+		Image:      inst.Config.Image,
+		State:      inst.State,
+		ExitCode:   inst.ExitCode,
+		Labels:     inst.Config.Labels,
+		StartedAt:  inst.StartedAt,
+		FinishedAt: inst.FinishedAt,
+	}
+}
+
+// memoryPages converts a memory limit in bytes to WebAssembly pages, rounding up.
+// No limit gives the module the whole 32-bit address space.
+func memoryPages(memory int64) uint32 {
+	pages := (memory + wasmPageSize - 1) / wasmPageSize
+	if memory <= 0 || pages > wasmMaxPages {
+		return wasmMaxPages
+	}
+	return uint32(pages)
+}
+
+// wasmExitCode reports the exit code of an instance the way Docker reports a container's: the code the
+// module passed to proc_exit, 137 when it was stopped and 1 when it trapped, e.g. by running out of memory.
+func wasmExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *sys.ExitError
+	if errors.As(err, &exitErr) {
+		switch exitErr.ExitCode() {
+		case sys.ExitCodeContextCanceled, sys.ExitCodeDeadlineExceeded:
+			return 137
+		default:
+			return int(exitErr.ExitCode())
+		}
+	}
+	return 1
+}
+
+func isURL(image string) bool {
+	return strings.HasPrefix(image, "http://") || strings.HasPrefix(image, "https://")
+}
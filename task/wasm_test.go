@@ -0,0 +1,237 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Function bodies of the modules the tests run, each without locals.
+var (
+	// exit3 calls proc_exit(3).
+	exit3 = []byte{0x00, 0x41, 0x03, 0x10, 0x00, 0x0b}
+
+	// spin loops forever.
+	spin = []byte{0x00, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x0b}
+
+	// grow adds a page to the memory, and traps if it can't.
+	grow = []byte{0x00, 0x41, 0x01, 0x40, 0x00, 0x41, 0x7f, 0x46, 0x04, 0x40, 0x00, 0x0b, 0x0b}
+)
+
+// wasmModule encodes a WASI command whose _start function runs body. The module imports proc_exit as
+// function 0 and has a memory of one page, which may grow.
+func wasmModule(body []byte) []byte {
+	section := func(id byte, content ...byte) []byte {
+		return append([]byte{id, byte(len(content))}, content...)
+	}
+	name := func(s string) []byte {
+		return append([]byte{byte(len(s))}, s...)
+	}
+
+	var imports []byte
+	imports = append(imports, 0x01)
+	imports = append(imports, name("wasi_snapshot_preview1")...)
+	imports = append(imports, name("proc_exit")...)
+	imports = append(imports, 0x00, 0x00)
+
+	var exports []byte
+	exports = append(exports, 0x01)
+	exports = append(exports, name("_start")...)
+	exports = append(exports, 0x00, 0x01)
+
+	module := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	module = append(module, section(0x01, 0x02, 0x60, 0x01, 0x7f, 0x00, 0x60, 0x00, 0x00)...) // (i32) -> () and () -> ()
+	module = append(module, section(0x02, imports...)...)
+	module = append(module, section(0x03, 0x01, 0x01)...)
+	module = append(module, section(0x05, 0x01, 0x00, 0x01)...)
+	module = append(module, section(0x07, exports...)...)
+	module = append(module, section(0x0a, append([]byte{0x01, byte(len(body))}, body...)...)...)
+	return module
+}
+
+// writeModule writes the module running body to a file and returns its path.
+func writeModule(tb testing.TB, body []byte) string {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), "module.wasm")
+	if err := os.WriteFile(path, wasmModule(body), 0600); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+func newTestWasm(tb testing.TB) *Wasm {
+	tb.Helper()
+	w, err := NewWasm(tb.TempDir())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return w
+}
+
+// startWasm pulls, creates and starts an instance of the module at image.
+func startWasm(tb testing.TB, w *Wasm, config Config) string {
+	tb.Helper()
+	ctx := context.Background()
+	config.Labels = map[string]string{LabelTaskID: uuid.NewString()}
+	if err := w.Pull(ctx, config.Image); err != nil {
+		tb.Fatal(err)
+	}
+	id, err := w.Create(ctx, config)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err := w.Start(ctx, id); err != nil {
+		tb.Fatal(err)
+	}
+	return id
+}
+
+func waitWasm(tb testing.TB, w *Wasm, id string) int {
+	tb.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	code, err := w.Wait(ctx, id)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return code
+}
+
+func TestWasmExitCode(t *testing.T) {
+	w := newTestWasm(t)
+	id := startWasm(t, w, Config{Image: writeModule(t, exit3)})
+
+	if code := waitWasm(t, w, id); code != 3 {
+		t.Errorf("Wait() = %d, want the 3 passed to proc_exit", code)
+	}
+	info, err := w.Inspect(context.Background(), id)
+	if err != nil || info.State != "exited" || info.ExitCode != 3 || info.FinishedAt.IsZero() {
+		t.Errorf("Inspect() = %+v, %v, want exited with 3", info, err)
+	}
+	if infos, _ := w.List(context.Background()); len(infos) != 1 || infos[0].ID != id {
+		t.Errorf("List() = %+v, want the instance", infos)
+	}
+
+	if err := w.Remove(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Inspect(context.Background(), id); err == nil {
+		t.Error("Inspect() error = nil for a removed instance")
+	}
+}
+
+func TestWasmMemoryLimit(t *testing.T) {
+	tests := []struct {
+		memory int64
+		want   int
+	}{
+		{0, 0},                // the whole address space.
+		{wasmPageSize, 1},     // the module can't grow past its single page, so it traps.
+		{2 * wasmPageSize, 0}, // room for the extra page.
+	}
+
+	module := writeModule(t, grow)
+	for _, tt := range tests {
+		w := newTestWasm(t)
+		id := startWasm(t, w, Config{Image: module, Memory: tt.memory})
+		if code := waitWasm(t, w, id); code != tt.want {
+			t.Errorf("Wait() = %d with a memory of %d bytes, want %d", code, tt.memory, tt.want)
+		}
+	}
+}
+
+func TestWasmStop(t *testing.T) {
+	w := newTestWasm(t)
+	id := startWasm(t, w, Config{Image: writeModule(t, spin)})
+
+	info, err := w.Inspect(context.Background(), id)
+	if err != nil || info.State != "running" {
+		t.Fatalf("Inspect() = %+v, %v, want it running", info, err)
+	}
+	if err := w.Remove(context.Background(), id); err == nil {
+		t.Error("Remove() error = nil for a running instance")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := w.Stop(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if code := waitWasm(t, w, id); code != 137 {
+		t.Errorf("Wait() = %d after Stop, want 137", code)
+	}
+}
+
+// TestNewWasmMarksRunningInstancesExited checks that the instances that were running in an earlier run
+// of the worker are reported as exited, since they died with it.
+func TestNewWasmMarksRunningInstancesExited(t *testing.T) {
+	first := newTestWasm(t)
+	id := startWasm(t, first, Config{Image: writeModule(t, spin)})
+	defer first.Stop(context.Background(), id)
+
+	second, err := NewWasm(first.StateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := second.Inspect(context.Background(), id)
+	if err != nil || info.State != "exited" || info.ExitCode != -1 {
+		t.Errorf("Inspect() = %+v, %v after a restart, want exited with -1", info, err)
+	}
+}
+
+func TestWasmPull(t *testing.T) {
+	module := wasmModule(exit3)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/module.wasm" {
+			http.NotFound(rw, r)
+			return
+		}
+		rw.Write(module)
+	}))
+	defer srv.Close()
+
+	w := newTestWasm(t)
+	id := startWasm(t, w, Config{Image: srv.URL + "/module.wasm"})
+	if code := waitWasm(t, w, id); code != 3 {
+		t.Errorf("Wait() = %d for a downloaded module, want 3", code)
+	}
+	path, _ := w.modulePath(srv.URL + "/module.wasm")
+	if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, module) {
+		t.Errorf("downloaded module = %d bytes, %v, want the %d served", len(data), err, len(module))
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.wasm")
+	if err := os.WriteFile(invalid, []byte("not wasm"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, image := range []string{srv.URL + "/missing.wasm", invalid, filepath.Join(t.TempDir(), "missing.wasm"), ""} {
+		if err := w.Pull(context.Background(), image); err == nil {
+			t.Errorf("Pull(%q) error = nil", image)
+		}
+	}
+}
+
+func TestMemoryPages(t *testing.T) {
+	tests := []struct {
+		memory int64
+		want   uint32
+	}{
+		{0, wasmMaxPages},
+		{1, 1},
+		{wasmPageSize, 1},
+		{wasmPageSize + 1, 2},
+		{8 << 30, wasmMaxPages},
+	}
+	for _, tt := range tests {
+		if got := memoryPages(tt.memory); got != tt.want {
+			t.Errorf("memoryPages(%d) = %d, want %d", tt.memory, got, tt.want)
+		}
+	}
+}
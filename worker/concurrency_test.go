@@ -0,0 +1,146 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"orchestra/task"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestConcurrentAccess hammers the worker's API, its processing loop and its checks at the same time.
+// It's meant to be run with -race, and also checks that every task is started without polling.
+func TestConcurrentAccess(t *testing.T) {
+	const (
+		clients        = 8
+		tasksPerClient = 25
+	)
+
+	srv, w, rt := newTestAPI(t)
+	rt.Latency = 100 * time.Microsecond
+	go w.ProcessTasks()
+
+	done := make(chan struct{})
+	var background sync.WaitGroup
+	loop := func(f func()) {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					f()
+					time.Sleep(100 * time.Microsecond)
+				}
+			}
+		}()
+	}
+	loop(w.UpdateTasks)
+	loop(w.UpdateTaskCount)
+	loop(w.CheckHealth)
+	loop(func() { w.GetTasks() })
+	loop(func() { w.CurrentStats() })
+	for _, path := range []string{"/tasks", "/stats", "/volumes"} {
+		loop(func() {
+			if resp, err := http.Get(srv.URL + path); err == nil {
+				resp.Body.Close()
+			}
+		})
+	}
+
+	var clientsDone sync.WaitGroup
+	ids := make(chan uuid.UUID, clients*tasksPerClient)
+	for c := 0; c < clients; c++ {
+		clientsDone.Add(1)
+		go func() {
+			defer clientsDone.Done()
+			for i := 0; i < tasksPerClient; i++ {
+				queued := newTask(task.Scheduled)
+				if i%5 == 0 {
+					queued.HealthCheck = &task.HealthCheck{Type: task.ExecCheck, Cmd: []string{"true"}, Interval: time.Millisecond}
+				}
+				data, _ := json.Marshal(task.TaskEvent{ID: uuid.New(), State: task.Scheduled, Task: queued})
+				if status, err := request(http.MethodPost, srv.URL+"/tasks", data); err != nil || status != http.StatusCreated {
+					t.Errorf("POST /tasks = %d, %v, want %d", status, err, http.StatusCreated)
+					return
+				}
+				ids <- queued.ID
+
+				// stop every other task as soon as it's known to the worker.
+				if i%2 == 0 {
+					if !waitFor(func() bool { got, ok := w.GetTask(queued.ID); return ok && got.State == task.Running }) {
+						t.Errorf("task %v was not started", queued.ID)
+						return
+					}
+					if status, err := request(http.MethodDelete, srv.URL+"/tasks/"+queued.ID.String(), nil); err != nil || status != http.StatusNoContent {
+						t.Errorf("DELETE /tasks/{id} = %d, %v, want %d", status, err, http.StatusNoContent)
+					}
+				}
+			}
+		}()
+	}
+	clientsDone.Wait()
+	close(ids)
+
+	// every task is either running or stopped once the queue has been drained.
+	settled := waitFor(func() bool {
+		if w.queueLen() != 0 {
+			return false
+		}
+		for _, got := range w.GetTasks() {
+			if got.State != task.Running && got.State != task.Completed {
+				return false
+			}
+		}
+		return true
+	})
+	close(done)
+	background.Wait()
+	if !settled {
+		t.Fatal("timed out waiting for the worker to run or stop every task")
+	}
+
+	count := 0
+	for id := range ids {
+		count++
+		if _, ok := w.GetTask(id); !ok {
+			t.Errorf("task %v was lost", id)
+		}
+	}
+	if got := len(w.GetTasks()); got != count {
+		t.Errorf("worker has %d tasks, want %d", got, count)
+	}
+}
+
+// request makes a request and returns the status of the response. Unlike doRequest, it can be called from
+// any goroutine.
+func request(method, url string, body []byte) (int, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// waitFor polls cond until it holds, and reports whether it did within a few seconds.
+func waitFor(cond func() bool) bool {
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
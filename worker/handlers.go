@@ -2,6 +2,7 @@ package worker
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -52,15 +53,14 @@ func (a *API) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	t, ok := a.Worker.Db[uuid]
+	t, ok := a.Worker.GetTask(uuid)
 	if !ok {
-		w.WriteHeader(http.StatusNotFound)
-		errMsg := fmt.Sprintf("Task not found: %v", err)
-		a.APIError(w, http.StatusBadRequest, errMsg)
+		errMsg := fmt.Sprintf("Task not found: %v", uuid)
+		a.APIError(w, http.StatusNotFound, errMsg)
 		return
 	}
 
-	copiedTask := *t
+	copiedTask := t
 	copiedTask.State = task.Completed
 	a.Worker.AddTask(copiedTask)
 
@@ -71,7 +71,35 @@ func (a *API) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
 func (a *API) GetStatsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(a.Worker.Stats)
+	json.NewEncoder(w).Encode(a.Worker.CurrentStats())
+}
+
+func (a *API) GetVolumesHandler(w http.ResponseWriter, r *http.Request) {
+	volumes, err := a.Worker.Volumes()
+	if err != nil {
+		a.APIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(volumes)
+}
+
+func (a *API) RemoveVolumeHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "volumeName")
+	err := a.Worker.RemoveVolume(name)
+	switch {
+	case err == nil:
+		log.Printf("Removed volume %s", name)
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, task.ErrVolumeNotFound):
+		a.APIError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, task.ErrVolumeInUse):
+		a.APIError(w, http.StatusConflict, err.Error())
+	default:
+		a.APIError(w, http.StatusInternalServerError, fmt.Sprintf("Unable to remove volume %s: %v", name, err))
+	}
 }
 
 //This is my own implementation of 'StartTaskHandler' based on my as of 'now' knowledge of Go
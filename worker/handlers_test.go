@@ -0,0 +1,209 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"orchestra/task"
+	"orchestra/task/fake"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newTestAPI serves the API of a worker backed by a fake runtime.
+func newTestAPI(tb testing.TB) (*httptest.Server, *Worker, *fake.Runtime) {
+	tb.Helper()
+	w, rt := newTestWorker()
+	a := &API{Worker: w}
+	a.initRouter()
+	srv := httptest.NewServer(a.Router)
+	tb.Cleanup(srv.Close)
+	return srv, w, rt
+}
+
+func postTaskEvent(tb testing.TB, url string, te task.TaskEvent) *http.Response {
+	tb.Helper()
+	data, err := json.Marshal(te)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	resp, err := http.Post(url+"/tasks", "application/json", bytes.NewReader(data))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return resp
+}
+
+func doRequest(tb testing.TB, method, url string) *http.Response {
+	tb.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return resp
+}
+
+func TestStartTaskHandler(t *testing.T) {
+	srv, w, _ := newTestAPI(t)
+	queued := newTask(task.Scheduled)
+
+	resp := postTaskEvent(t, srv.URL, task.TaskEvent{ID: uuid.New(), State: task.Scheduled, TimeStamp: time.Now(), Task: queued})
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /tasks status = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	var got task.Task
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != queued.ID {
+		t.Errorf("POST /tasks returned task %v, want %v", got.ID, queued.ID)
+	}
+
+	if result := w.RunTask(); result.Error != nil {
+		t.Fatalf("RunTask() error = %v", result.Error)
+	}
+	if got := mustGetTask(t, w, queued.ID); got.State != task.Running {
+		t.Errorf("task state = %v, want %v", got.State, task.Running)
+	}
+}
+
+func TestStartTaskHandlerRejectsInvalidBody(t *testing.T) {
+	srv, w, _ := newTestAPI(t)
+
+	for _, body := range []string{"not json", `{"Task": {"ID": "` + uuid.NewString() + `"}, "Unknown": 1}`} {
+		resp, err := http.Post(srv.URL+"/tasks", "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("POST /tasks with %q status = %d, want %d", body, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+	if n := w.queueLen(); n != 0 {
+		t.Errorf("%d tasks queued from invalid requests", n)
+	}
+}
+
+func TestGetTaskHandler(t *testing.T) {
+	srv, w, _ := newTestAPI(t)
+	queued := newTask(task.Scheduled)
+	w.AddTask(queued)
+	if result := w.RunTask(); result.Error != nil {
+		t.Fatalf("RunTask() error = %v", result.Error)
+	}
+
+	resp := doRequest(t, http.MethodGet, srv.URL+"/tasks")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /tasks status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	var tasks []task.Task
+	if err := json.NewDecoder(resp.Body).Decode(&tasks); err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != queued.ID || tasks[0].State != task.Running {
+		t.Errorf("GET /tasks = %+v, want the running task %v", tasks, queued.ID)
+	}
+}
+
+func TestStopTaskHandler(t *testing.T) {
+	srv, w, rt := newTestAPI(t)
+	queued := newTask(task.Scheduled)
+	w.AddTask(queued)
+	if result := w.RunTask(); result.Error != nil {
+		t.Fatalf("RunTask() error = %v", result.Error)
+	}
+
+	resp := doRequest(t, http.MethodDelete, srv.URL+"/tasks/"+queued.ID.String())
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE /tasks/{id} status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	if result := w.RunTask(); result.Error != nil {
+		t.Fatalf("RunTask() error = %v", result.Error)
+	}
+	if got := mustGetTask(t, w, queued.ID); got.State != task.Completed {
+		t.Errorf("task state = %v, want %v", got.State, task.Completed)
+	}
+	if containers := rt.Containers(); len(containers) != 0 {
+		t.Errorf("runtime has %d containers after the task was stopped", len(containers))
+	}
+}
+
+func TestStopTaskHandlerErrors(t *testing.T) {
+	srv, _, _ := newTestAPI(t)
+
+	tests := []struct {
+		id   string
+		want int
+	}{
+		{"not-a-uuid", http.StatusBadRequest},
+		{uuid.NewString(), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		resp := doRequest(t, http.MethodDelete, srv.URL+"/tasks/"+tt.id)
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("DELETE /tasks/%s status = %d, want %d", tt.id, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestVolumeHandlers(t *testing.T) {
+	srv, w, rt := newTestAPI(t)
+	queued := newTask(task.Scheduled)
+	queued.Mounts = []task.Mount{{Type: task.Volume, Source: "data", Target: "/data"}}
+	w.AddTask(queued)
+	result := w.RunTask()
+	if result.Error != nil {
+		t.Fatalf("RunTask() error = %v", result.Error)
+	}
+
+	resp := doRequest(t, http.MethodGet, srv.URL+"/volumes")
+	var volumes []task.VolumeInfo
+	err := json.NewDecoder(resp.Body).Decode(&volumes)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(volumes) != 1 || volumes[0].Name != "data" {
+		t.Fatalf("GET /volumes = %+v, want the data volume", volumes)
+	}
+
+	steps := []struct {
+		name string
+		want int
+	}{
+		{"data", http.StatusConflict}, // the task's container still uses it.
+		{"missing", http.StatusNotFound},
+	}
+	for _, s := range steps {
+		resp := doRequest(t, http.MethodDelete, srv.URL+"/volumes/"+s.name)
+		resp.Body.Close()
+		if resp.StatusCode != s.want {
+			t.Errorf("DELETE /volumes/%s status = %d, want %d", s.name, resp.StatusCode, s.want)
+		}
+	}
+
+	if err := rt.Exit(result.ContainerId, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Remove(context.Background(), result.ContainerId); err != nil {
+		t.Fatal(err)
+	}
+	resp = doRequest(t, http.MethodDelete, srv.URL+"/volumes/data")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE /volumes/data status = %d once unused, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+}
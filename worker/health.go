@@ -0,0 +1,320 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"orchestra/task"
+	"strings"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+)
+
+const (
+	// maxHealthOutput caps the output of a check kept in the history of a task.
+	maxHealthOutput = 256
+
+	// DefaultHealthRestartBackoff is how long a task restarted for being unhealthy waits before it's restarted
+	// again for it, when the worker's HealthRestartBackoff isn't set.
+	DefaultHealthRestartBackoff = 10 * time.Second
+
+	// DefaultMaxHealthRestartBackoff caps that wait when the worker's MaxHealthRestartBackoff isn't set.
+	DefaultMaxHealthRestartBackoff = 5 * time.Minute
+)
+
+// healthState is what the worker keeps between the health checks of a running task.
+type healthState struct {
+	container  string    // container is the container the checks are about; the state starts over with a new one.
+	next       time.Time // next is when the next check is due.
+	failures   int       // failures counts the checks that failed in a row.
+	checking   bool      // checking is set while a check or a restart of the task is under way.
+	restarting bool      // restarting is set while the task is restarted for being unhealthy.
+
+	// the restarts for being unhealthy carry over to the next containers of the task.
+	restarts  int       // restarts counts the restarts since the backoff last started over.
+	restartAt time.Time // restartAt is when the task may be restarted again.
+}
+
+// backOff records a restart of the task at now and sets when it may be restarted again: initial after the
+// first restart, then twice as long after every other up to limit. It starts over once the task went on for
+// limit past the end of its last backoff without having to be restarted.
+func (h *healthState) backOff(now time.Time, initial, limit time.Duration) {
+	if now.Sub(h.restartAt) >= limit {
+		h.restarts = 0
+	}
+	backoff := initial
+	for i := 0; i < h.restarts && backoff < limit; i++ {
+		backoff *= 2
+	}
+	h.restarts++
+	h.restartAt = now.Add(min(backoff, limit))
+}
+
+// CheckHealthForever runs CheckHealth every tick.
+func (w *Worker) CheckHealthForever(tick time.Duration) {
+	for {
+		w.CheckHealth()
+		time.Sleep(tick)
+	}
+}
+
+// CheckHealth starts the health checks that are due for the running tasks that have a HealthCheck. The first
+// check of a container happens an Interval after it's noticed, and the next ones an Interval after the previous.
+func (w *Worker) CheckHealth() {
+	now := time.Now().UTC()
+	var due []task.Task
+
+	w.mu.Lock()
+	for id := range w.health {
+		if t, ok := w.Db[id]; !ok || t.HealthCheck == nil || (t.State != task.Running && !w.health[id].restarting) {
+			delete(w.health, id)
+		}
+	}
+	for id, t := range w.Db {
+		if t.HealthCheck == nil || t.State != task.Running {
+			continue
+		}
+
+		h, ok := w.health[id]
+		if !ok || (h.container != t.Runtime.ContainerId && !h.restarting) {
+			fresh := &healthState{container: t.Runtime.ContainerId, next: now.Add(t.HealthCheck.WithDefaults().Interval)}
+			if ok {
+				fresh.restarts, fresh.restartAt = h.restarts, h.restartAt
+			}
+			h = fresh
+			w.health[id] = h
+		}
+		if h.checking || now.Before(h.next) {
+			continue
+		}
+		h.checking = true
+		due = append(due, *t)
+	}
+	w.mu.Unlock()
+
+	for _, t := range due {
+		go w.checkHealth(t)
+	}
+}
+
+// checkHealth checks the task once and records the result on it. A task whose FailureThreshold last checks
+// failed becomes Unhealthy, and is restarted unless its RestartPolicy says it mustn't be or it was restarted
+// for it too recently, see healthState.backOff.
+func (w *Worker) checkHealth(t task.Task) {
+	check := t.HealthCheck.WithDefaults()
+	result := w.probe(t, check)
+
+	w.mu.Lock()
+	h, ok := w.health[t.ID]
+	current, found := w.Db[t.ID]
+	if !ok || !found || h.container != t.Runtime.ContainerId || current.State != task.Running || current.Runtime.ContainerId != t.Runtime.ContainerId {
+		// the task stopped or changed container while it was being checked.
+		if ok {
+			h.checking = false
+		}
+		w.mu.Unlock()
+		return
+	}
+
+	h.next = result.Time.Add(check.Interval)
+	updated := *current
+	updated.HealthHistory = append(append([]task.HealthResult{}, current.HealthHistory...), result)
+	if len(updated.HealthHistory) > task.MaxHealthHistory {
+		updated.HealthHistory = updated.HealthHistory[len(updated.HealthHistory)-task.MaxHealthHistory:]
+	}
+	if result.Healthy {
+		h.failures = 0
+		updated.Health = task.Healthy
+	} else {
+		h.failures++
+		if h.failures >= check.FailureThreshold {
+			updated.Health = task.Unhealthy
+		}
+	}
+	w.saveTaskLocked(&updated)
+
+	failed := *current
+	failed.State = task.Failed
+	restart := updated.Health == task.Unhealthy && !result.Healthy && h.failures >= check.FailureThreshold && failed.ShouldRestart()
+	if restart && result.Time.Before(h.restartAt) {
+		log.Printf("Task %v is unhealthy but was restarted for it recently, backing off until %v", t.ID, h.restartAt)
+		restart = false
+	}
+	if !restart {
+		if !result.Healthy {
+			log.Printf("Health check of task %v failed, %d in a row: %s", t.ID, h.failures, result.Output)
+		}
+		h.checking = false
+		w.mu.Unlock()
+		return
+	}
+	h.restarting = true
+	initial, limit := w.healthRestartBackoff()
+	h.backOff(result.Time, initial, limit)
+	w.mu.Unlock()
+
+	log.Printf("Task %v failed %d health checks in a row, restarting it", t.ID, check.FailureThreshold)
+	w.restartUnhealthy(updated)
+
+	w.mu.Lock()
+	if h, ok := w.health[t.ID]; ok {
+		h.failures = 0
+		h.checking = false
+		h.restarting = false
+	}
+	w.mu.Unlock()
+}
+
+// healthRestartBackoff returns the initial and the longest wait between two restarts of a task for being
+// unhealthy.
+func (w *Worker) healthRestartBackoff() (time.Duration, time.Duration) {
+	initial, limit := w.HealthRestartBackoff, w.MaxHealthRestartBackoff
+	if initial <= 0 {
+		initial = DefaultHealthRestartBackoff
+	}
+	if limit <= 0 {
+		limit = DefaultMaxHealthRestartBackoff
+	}
+	return initial, limit
+}
+
+// restartUnhealthy stops the container of an unhealthy task and runs the task again in a new one, unless the
+// task was stopped meanwhile, e.g. by the manager. Stops queued meanwhile wait for the restart to be over, and
+// then stop the new container.
+func (w *Worker) restartUnhealthy(t task.Task) {
+	w.lifecycle.Lock()
+	defer w.lifecycle.Unlock()
+
+	rt, err := w.runtimeFor(t.RuntimeName)
+	if err != nil {
+		log.Printf("Unable to restart task %v: %v", t.ID, err)
+		return
+	}
+	if !w.isStillRunning(t) {
+		log.Printf("Task %v was stopped before it could be restarted", t.ID)
+		return
+	}
+	if err := rt.Stop(context.Background(), t.Runtime.ContainerId); err != nil {
+		log.Printf("Error stopping container %s of unhealthy task %v: %v", t.Runtime.ContainerId, t.ID, err)
+	}
+	// stopping the container takes a while, during which the task may have been stopped for good.
+	if !w.isStillRunning(t) {
+		log.Printf("Task %v was stopped while it was being restarted", t.ID)
+		return
+	}
+	w.StartTask(t)
+}
+
+// isStillRunning reports whether the worker still has the task running in the same container, i.e. it hasn't
+// been stopped, completed or failed since t was read.
+func (w *Worker) isStillRunning(t task.Task) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	current, ok := w.Db[t.ID]
+	return ok && current.State == task.Running && current.Runtime.ContainerId == t.Runtime.ContainerId
+}
+
+// probe runs one check of the task.
+func (w *Worker) probe(t task.Task, check task.HealthCheck) task.HealthResult {
+	result := task.HealthResult{Time: time.Now().UTC()}
+	ctx, cancel := context.WithTimeout(context.Background(), check.Timeout)
+	defer cancel()
+
+	output, err := w.runProbe(ctx, t, check)
+	result.Healthy = err == nil
+	if err != nil {
+		output = err.Error()
+	}
+	if len(output) > maxHealthOutput {
+		output = output[:maxHealthOutput]
+	}
+	result.Output = strings.TrimSpace(output)
+	return result
+}
+
+// runProbe runs a check of the task and returns what it got back, or why the check failed.
+func (w *Worker) runProbe(ctx context.Context, t task.Task, check task.HealthCheck) (string, error) {
+	rt, err := w.runtimeFor(t.RuntimeName)
+	if err != nil {
+		return "", err
+	}
+
+	if check.Type == task.ExecCheck {
+		executor, ok := rt.(task.Executor)
+		if !ok {
+			return "", fmt.Errorf("the %s runtime can't run commands in containers", t.RuntimeName)
+		}
+		code, output, err := executor.Exec(ctx, t.Runtime.ContainerId, check.Cmd)
+		if err != nil {
+			return output, err
+		}
+		if code != 0 {
+			return "", fmt.Errorf("exited with code %d: %s", code, output)
+		}
+		return output, nil
+	}
+
+	addr, err := probeAddress(ctx, rt, t, check.Port)
+	if err != nil {
+		return "", err
+	}
+
+	if check.Type == task.TCPCheck {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return "", err
+		}
+		conn.Close()
+		return "connected to " + addr, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s%s", addr, check.Path), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return "", fmt.Errorf("HTTP %s", resp.Status)
+	}
+	return "HTTP " + resp.Status, nil
+}
+
+// probeAddress returns the address HTTP and TCP checks of the task reach the container port at: the host port
+// the port is bound to, or else the port on the container's address, or on the host's for runtimes whose
+// containers share the host's network.
+func probeAddress(ctx context.Context, rt task.Runtime, t task.Task, port string) (string, error) {
+	proto, number := nat.SplitProtoPort(port)
+	for p, host := range t.PortBindings {
+		bindingProto, bindingNumber := nat.SplitProtoPort(p)
+		if bindingProto != proto || bindingNumber != number {
+			continue
+		}
+		ip, hostPort, err := net.SplitHostPort(host)
+		if err != nil {
+			ip, hostPort = "", host
+		}
+		if ip == "" || ip == "0.0.0.0" || ip == "::" {
+			ip = "127.0.0.1"
+		}
+		return net.JoinHostPort(ip, hostPort), nil
+	}
+
+	info, err := rt.Inspect(ctx, t.Runtime.ContainerId)
+	if err != nil {
+		return "", err
+	}
+	ip := info.IPAddress
+	if ip == "" {
+		ip = "127.0.0.1"
+	}
+	return net.JoinHostPort(ip, number), nil
+}
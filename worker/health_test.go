@@ -0,0 +1,278 @@
+package worker
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"orchestra/task"
+	"orchestra/task/fake"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// runChecked runs a task with the given health check, checked as often as the test asks, and returns it running.
+func runChecked(tb testing.TB, w *Worker, t task.Task, check task.HealthCheck) task.Task {
+	tb.Helper()
+	t.DesiredState = task.Running
+	check.Interval = time.Nanosecond
+	t.HealthCheck = &check
+	w.AddTask(t)
+	if result := w.RunTask(); result.Error != nil {
+		tb.Fatalf("RunTask() error = %v", result.Error)
+	}
+	return mustGetTask(tb, w, t.ID)
+}
+
+// healthOf returns a copy of the state of the health checks of a task.
+func healthOf(w *Worker, id uuid.UUID) (healthState, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	h, ok := w.health[id]
+	if !ok {
+		return healthState{}, false
+	}
+	return *h, true
+}
+
+// checkNow runs one health check of the task and returns the task once the check, and the restart it may have
+// led to, are over.
+func checkNow(tb testing.TB, w *Worker, id uuid.UUID) task.Task {
+	tb.Helper()
+	before := len(mustGetTask(tb, w, id).HealthHistory)
+	checked := waitFor(func() bool {
+		h, ok := healthOf(w, id)
+		if ok && !h.checking && len(mustGetTask(tb, w, id).HealthHistory) > before {
+			return true
+		}
+		w.CheckHealth()
+		return false
+	})
+	if !checked {
+		tb.Fatalf("task %v wasn't checked", id)
+	}
+	return mustGetTask(tb, w, id)
+}
+
+// lastCheck returns the result of the last health check of the task.
+func lastCheck(t task.Task) task.HealthResult {
+	return t.HealthHistory[len(t.HealthHistory)-1]
+}
+
+func TestHTTPHealthCheck(t *testing.T) {
+	var status atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path != "/healthz":
+			rw.WriteHeader(http.StatusNotFound)
+		case status.Load() == 0:
+			// hang until the check times out.
+			<-r.Context().Done()
+		default:
+			rw.WriteHeader(int(status.Load()))
+		}
+	}))
+	defer srv.Close()
+
+	w, _ := newTestWorker()
+	queued := newTask(task.Scheduled)
+	queued.RestartPolicy = "no"
+	queued.PortBindings = map[string]string{"8080": srv.Listener.Addr().String()}
+	running := runChecked(t, w, queued, task.HealthCheck{Type: task.HTTPCheck, Port: "8080", Path: "/healthz", Timeout: 50 * time.Millisecond, FailureThreshold: 1})
+
+	tests := []struct {
+		status int
+		want   task.Health
+		output string
+	}{
+		{http.StatusOK, task.Healthy, "HTTP 200 OK"},
+		{http.StatusFound, task.Healthy, "HTTP 302 Found"},
+		{http.StatusServiceUnavailable, task.Unhealthy, "HTTP 503 Service Unavailable"},
+		{0, task.Unhealthy, "context deadline exceeded"},
+		{http.StatusNoContent, task.Healthy, "HTTP 204 No Content"},
+	}
+	for _, tt := range tests {
+		status.Store(int32(tt.status))
+		got := checkNow(t, w, running.ID)
+		if got.Health != tt.want || !strings.Contains(lastCheck(got).Output, tt.output) {
+			t.Errorf("status %d: task is %q after %+v, want %q with output %q", tt.status, got.Health, lastCheck(got), tt.want, tt.output)
+		}
+	}
+}
+
+func TestTCPHealthCheck(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	w, _ := newTestWorker()
+	queued := newTask(task.Scheduled)
+	queued.RestartPolicy = "no"
+	queued.PortBindings = map[string]string{"5432/tcp": ln.Addr().String()}
+	running := runChecked(t, w, queued, task.HealthCheck{Type: task.TCPCheck, Port: "5432", FailureThreshold: 1})
+
+	if got := checkNow(t, w, running.ID); got.Health != task.Healthy {
+		t.Errorf("task is %q while its port accepts connections, after %+v", got.Health, lastCheck(got))
+	}
+	ln.Close()
+	if got := checkNow(t, w, running.ID); got.Health != task.Unhealthy {
+		t.Errorf("task is %q once its port refuses connections, after %+v", got.Health, lastCheck(got))
+	}
+}
+
+func TestExecHealthCheck(t *testing.T) {
+	w, rt := newTestWorker()
+	queued := newTask(task.Scheduled)
+	queued.RestartPolicy = "no"
+	running := runChecked(t, w, queued, task.HealthCheck{Type: task.ExecCheck, Cmd: []string{"pg_isready"}, FailureThreshold: 1})
+
+	if err := rt.ScriptExec(running.Runtime.ContainerId, 0, "accepting connections\n"); err != nil {
+		t.Fatal(err)
+	}
+	got := checkNow(t, w, running.ID)
+	if got.Health != task.Healthy || lastCheck(got).Output != "accepting connections" {
+		t.Errorf("task is %q after %+v, want it healthy with the command's output", got.Health, lastCheck(got))
+	}
+
+	if err := rt.ScriptExec(running.Runtime.ContainerId, 2, "no response"); err != nil {
+		t.Fatal(err)
+	}
+	got = checkNow(t, w, running.ID)
+	if got.Health != task.Unhealthy || lastCheck(got).Output != "exited with code 2: no response" {
+		t.Errorf("task is %q after %+v, want it unhealthy with the exit code and output", got.Health, lastCheck(got))
+	}
+}
+
+// TestHealthFailureThreshold checks that a task only turns unhealthy, and is restarted, once FailureThreshold
+// checks in a row failed.
+func TestHealthFailureThreshold(t *testing.T) {
+	w, rt := newTestWorker()
+	running := runChecked(t, w, newTask(task.Scheduled), task.HealthCheck{Type: task.ExecCheck, Cmd: []string{"true"}, FailureThreshold: 3})
+	old := running.Runtime.ContainerId
+
+	if got := checkNow(t, w, running.ID); got.Health != task.Healthy {
+		t.Fatalf("task is %q after %+v, want it healthy", got.Health, lastCheck(got))
+	}
+	if err := rt.ScriptExec(old, 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < 3; i++ {
+		if got := checkNow(t, w, running.ID); got.Health != task.Healthy || got.Runtime.ContainerId != old {
+			t.Fatalf("task is %q in container %s after %d failed checks, want it left healthy in %s", got.Health, got.Runtime.ContainerId, i, old)
+		}
+	}
+
+	got := checkNow(t, w, running.ID)
+	if got.State != task.Running || got.Runtime.ContainerId == old {
+		t.Fatalf("task is %v in container %s after 3 failed checks, want it restarted in a new container", got.State, got.Runtime.ContainerId)
+	}
+	if last := lastCheck(got); last.Healthy {
+		t.Errorf("last check of the restarted task = %+v, want the failed one kept", last)
+	}
+	if containers := rt.Containers(); len(containers) != 1 || containers[0].ID != got.Runtime.ContainerId {
+		t.Errorf("runtime has containers %+v, want only the new one", containers)
+	}
+}
+
+func TestHealthRestartBacksOff(t *testing.T) {
+	w, rt := newTestWorker()
+	w.HealthRestartBackoff = time.Hour
+	running := runChecked(t, w, newTask(task.Scheduled), task.HealthCheck{Type: task.ExecCheck, Cmd: []string{"true"}, FailureThreshold: 1})
+
+	// the first restart is immediate.
+	if err := rt.ScriptExec(running.Runtime.ContainerId, 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	restarted := checkNow(t, w, running.ID)
+	if restarted.Runtime.ContainerId == running.Runtime.ContainerId {
+		t.Fatal("unhealthy task wasn't restarted")
+	}
+
+	// the new container is just as unhealthy, but the task was restarted too recently to be restarted again.
+	if err := rt.ScriptExec(restarted.Runtime.ContainerId, 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	got := checkNow(t, w, running.ID)
+	if got.Health != task.Unhealthy || got.Runtime.ContainerId != restarted.Runtime.ContainerId {
+		t.Errorf("task is %q in container %s, want it left unhealthy in %s", got.Health, got.Runtime.ContainerId, restarted.Runtime.ContainerId)
+	}
+	if h, _ := healthOf(w, running.ID); h.restarts != 1 {
+		t.Errorf("health state counts %d restarts, want the restart carried over to the new container", h.restarts)
+	}
+}
+
+func TestHealthStateBackOff(t *testing.T) {
+	var h healthState
+	now := time.Now()
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		h.backOff(now, time.Second, 5*time.Second)
+		if got := h.restartAt.Sub(now); got != want {
+			t.Errorf("backoff after restart %d = %v, want %v", i+1, got, want)
+		}
+		now = h.restartAt
+	}
+
+	// a task that went on for the longest backoff past the end of the last one starts over.
+	now = now.Add(5 * time.Second)
+	h.backOff(now, time.Second, 5*time.Second)
+	if got := h.restartAt.Sub(now); got != time.Second {
+		t.Errorf("backoff after running for the longest backoff = %v, want %v", got, time.Second)
+	}
+}
+
+// TestHealthRestartRacesStop checks that a task stopped while it's restarted for being unhealthy is stopped
+// for good, rather than left running in the container the restart started.
+func TestHealthRestartRacesStop(t *testing.T) {
+	w, rt := newTestWorker()
+	running := runChecked(t, w, newTask(task.Scheduled), task.HealthCheck{Type: task.ExecCheck, Cmd: []string{"true"}, FailureThreshold: 1})
+	if err := rt.ScriptExec(running.Runtime.ContainerId, 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	rt.Latency = 20 * time.Millisecond
+
+	// wait for the restart to be stopping the unhealthy container.
+	stopping := waitFor(func() bool {
+		for _, call := range rt.Calls() {
+			if call.Op == fake.Stop && call.Arg == running.Runtime.ContainerId {
+				return true
+			}
+		}
+		w.CheckHealth()
+		return false
+	})
+	if !stopping {
+		t.Fatal("unhealthy task wasn't restarted")
+	}
+
+	stopped := running
+	stopped.State = task.Completed
+	w.AddTask(stopped)
+	if result := w.RunTask(); result.Error != nil {
+		t.Fatalf("RunTask() error = %v", result.Error)
+	}
+	if !waitFor(func() bool { h, ok := healthOf(w, running.ID); return !ok || !h.checking }) {
+		t.Fatal("the restart never ended")
+	}
+
+	if got := mustGetTask(t, w, running.ID); got.State != task.Completed {
+		t.Errorf("task state = %v, want %v", got.State, task.Completed)
+	}
+	for _, c := range rt.Containers() {
+		if c.Running() {
+			t.Errorf("container %s is still running after the task was stopped", c.ID)
+		}
+	}
+}
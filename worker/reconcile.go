@@ -0,0 +1,186 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"orchestra/task"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reconcile loads the task records kept in the Store and checks them against the containers
+// the runtime actually has, matching the two through the task labels set on every container.
+//
+//  1. A task whose container is running is kept as Running.
+//  2. A task whose container exited is marked Completed or Failed depending on its exit code.
+//  3. A task whose container vanished is marked Failed.
+//  4. A running container that belongs to no known task is adopted as a Running task.
+//  5. A stopped container that belongs to no known task is removed.
+func (w *Worker) Reconcile() error {
+	tasks, err := w.Store.List()
+	if err != nil {
+		return fmt.Errorf("unable to load tasks from the store: %w", err)
+	}
+	known := make(map[uuid.UUID]bool, len(tasks))
+	w.mu.Lock()
+	for _, t := range tasks {
+		known[t.ID] = true
+		w.Db[t.ID] = &t
+	}
+	w.mu.Unlock()
+
+	type found struct {
+		info    task.ContainerInfo
+		name    string
+		runtime task.Runtime
+	}
+
+	ctx := context.Background()
+	listed := make(map[task.Runtime]bool)
+	byTask := make(map[uuid.UUID]found)
+	for name, rt := range w.allRuntimes() {
+		containers, err := rt.List(ctx)
+		if err != nil {
+			// without the containers of this runtime, its tasks can't be told apart from vanished ones.
+			log.Printf("Error listing the containers of runtime %q, leaving its tasks as they are: %v", name, err)
+			continue
+		}
+		listed[rt] = true
+
+		for _, c := range containers {
+			id, err := uuid.Parse(c.Labels[task.LabelTaskID])
+			if err != nil {
+				log.Printf("Container %s has an invalid %s label, ignoring it", c.ID, task.LabelTaskID)
+				continue
+			}
+			byTask[id] = found{info: c, name: name, runtime: rt}
+		}
+	}
+
+	now := time.Now().UTC()
+	for _, t := range tasks {
+		if t.State != task.Scheduled && t.State != task.Running {
+			continue
+		}
+
+		rt, err := w.runtimeFor(t.RuntimeName)
+		if err != nil || !listed[rt] {
+			continue
+		}
+
+		f, ok := byTask[t.ID]
+		if observe(&t, f.info, ok, now) {
+			w.saveTask(&t)
+		}
+	}
+
+	for id, f := range byTask {
+		if known[id] {
+			continue
+		}
+
+		c := f.info
+		if !c.Running() {
+			log.Printf("Removing container %s left behind by unknown task %v", c.ID, id)
+			if err := f.runtime.Remove(ctx, c.ID); err != nil {
+				log.Printf("Error removing container %s: %v", c.ID, err)
+			}
+			continue
+		}
+
+		log.Printf("Adopting running container %s of unknown task %v", c.ID, id)
+		w.saveTask(&task.Task{
+			ID:          id,
+			Name:        c.Labels[task.LabelTaskName],
+			State:       task.Running,
+			Image:       c.Image,
+			StartTime:   c.StartedAt,
+			Runtime:     task.RuntimeInfo{ContainerId: c.ID},
+			RuntimeName: f.name,
+		})
+	}
+
+	return nil
+}
+
+// UpdateTasks inspects the container of every running task and records the tasks whose container
+// exited or vanished, e.g. because it crashed or was stopped by hand, so the manager sees the drift.
+func (w *Worker) UpdateTasks() {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for _, t := range w.GetTasks() {
+		if t.State != task.Running {
+			continue
+		}
+
+		rt, err := w.runtimeFor(t.RuntimeName)
+		if err != nil {
+			log.Printf("Unable to check task %v: %v", t.ID, err)
+			continue
+		}
+
+		c, err := rt.Inspect(ctx, t.Runtime.ContainerId)
+		found := err == nil
+		if err != nil && !errors.Is(err, task.ErrNotFound) {
+			log.Printf("Error inspecting container %s of task %v: %v", t.Runtime.ContainerId, t.ID, err)
+			continue
+		}
+
+		observed := t
+		if observe(&observed, c, found, now) {
+			w.saveTaskIf(&observed, t)
+		}
+	}
+}
+
+// saveTaskIf saves t unless its record in Db changed since it was read as previous, e.g. because the task
+// was stopped while its container was being inspected, or the task is being restarted for being unhealthy.
+func (w *Worker) saveTaskIf(t *task.Task, previous task.Task) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	current, ok := w.Db[t.ID]
+	if !ok || current.State != previous.State || current.Runtime.ContainerId != previous.Runtime.ContainerId {
+		return
+	}
+	if h, ok := w.health[t.ID]; ok && h.restarting {
+		return
+	}
+	w.saveTaskLocked(t)
+}
+
+// UpdateTasksForever runs UpdateTasks every interval.
+func (w *Worker) UpdateTasksForever(interval time.Duration) {
+	for {
+		log.Println("Checking the containers of running tasks")
+		w.UpdateTasks()
+		time.Sleep(interval)
+	}
+}
+
+// observe updates the state of a task from what Docker reports about its container, found being false
+// when the container no longer exists. It reports whether the state of the task changed.
+func observe(t *task.Task, c task.ContainerInfo, found bool, now time.Time) bool {
+	previous := t.State
+	switch {
+	case !found:
+		log.Printf("Container of task %v vanished, marking the task failed", t.ID)
+		t.State = task.Failed
+		t.FinishTime = now
+	case c.Running():
+		t.State = task.Running
+		t.Runtime.ContainerId = c.ID
+	case c.ExitCode == 0:
+		log.Printf("Container %s of task %v exited, marking the task completed", c.ID, t.ID)
+		t.State = task.Completed
+		t.FinishTime = c.FinishedAt
+	default:
+		log.Printf("Container %s of task %v exited with code %d, marking the task failed", c.ID, t.ID, c.ExitCode)
+		t.State = task.Failed
+		t.FinishTime = c.FinishedAt
+	}
+	return t.State != previous
+}
@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"orchestra/node"
+	"runtime"
+	"time"
+)
+
+// errNotRegistered is returned by Heartbeat when the manager doesn't know about the worker,
+// e.g. because the manager restarted or declared the worker gone.
+var errNotRegistered = errors.New("worker is not registered with the manager")
+
+// Node describes this worker and its capacity the way the manager sees it.
+func (w *Worker) Node() node.Node {
+	n := node.NewNode(w.Name, w.Address, "worker")
+	n.Labels = w.Labels
+	n.Reserved = w.Reserved
+	n.Overcommit = w.Overcommit
+	if host, _, err := net.SplitHostPort(w.Address); err == nil {
+		n.IpAddr = host
+	}
+
+	stats := w.CurrentStats()
+	if stats == nil {
+		stats = GetStats()
+	}
+	n.Cores = runtime.NumCPU()
+	n.Memory = int(stats.TotalMemKb() * 1024)
+	n.Disk = int(stats.TotalDisk())
+	n.MemoryAvailable = int(stats.AvailableMemKb() * 1024)
+	if stats.LoadStats != nil {
+		n.Load = stats.LoadStats.Last1Min
+	}
+	n.TaskCount = stats.TaskCount
+
+	return *n
+}
+
+// Register announces the worker and its capacity to the manager.
+func (w *Worker) Register() error {
+	return w.sendNode(fmt.Sprintf("http://%s/nodes", w.Manager), http.StatusCreated)
+}
+
+// Heartbeat lets the manager know that the worker is still alive.
+func (w *Worker) Heartbeat() error {
+	return w.sendNode(fmt.Sprintf("http://%s/nodes/%s/heartbeat", w.Manager, w.Name), http.StatusOK)
+}
+
+// SendHeartbeats registers the worker with the manager and then sends a heartbeat every interval.
+// The worker registers again whenever the manager no longer recognises it.
+func (w *Worker) SendHeartbeats(interval time.Duration) {
+	registered := false
+	for {
+		var err error
+		if registered {
+			err = w.Heartbeat()
+		} else {
+			err = w.Register()
+		}
+
+		switch {
+		case err == nil:
+			if !registered {
+				log.Printf("Registered worker %s with manager %s", w.Name, w.Manager)
+			}
+			registered = true
+		case errors.Is(err, errNotRegistered):
+			log.Printf("Manager %s no longer knows worker %s, registering again", w.Manager, w.Name)
+			registered = false
+			continue
+		default:
+			log.Printf("Error contacting manager %s: %v", w.Manager, err)
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func (w *Worker) sendNode(url string, expected int) error {
+	data, err := json.Marshal(w.Node())
+	if err != nil {
+		return fmt.Errorf("unable to marshal node: %w", err)
+	}
+
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case expected:
+		return nil
+	case http.StatusNotFound:
+		return errNotRegistered
+	default:
+		e := ErrorResponse{}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, e.Message)
+	}
+}
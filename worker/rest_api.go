@@ -46,6 +46,11 @@ func (a *API) initRouter() {
 		})
 	})
 
+	a.Router.Route("/volumes", func(r chi.Router) {
+		r.Get("/", a.GetVolumesHandler)
+		r.Delete("/{volumeName}", a.RemoveVolumeHandler)
+	})
+
 	a.Router.Route("/stats", func(r chi.Router) {
 		r.Get("/", a.GetStatsHandler)
 	})
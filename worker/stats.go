@@ -103,9 +103,10 @@ func GetMemoryInfo() *linux.MemInfo {
 }
 
 func GetDiskInfo() *linux.Disk {
-	diskstats, err := linux.ReadDisk("/proc/diskstats")
+	// ReadDisk runs statfs on the given path, so point it at the root filesystem.
+	diskstats, err := linux.ReadDisk("/")
 	if err != nil {
-		log.Println("Error reading from linux `/proc/diskstats` file")
+		log.Println("Error reading disk usage of the root filesystem")
 		return &linux.Disk{}
 	}
 
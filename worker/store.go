@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"encoding/json"
+	"fmt"
+	"orchestra/task"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Store persists the worker's task records so that they survive a restart of the worker.
+// The worker works on its Db map and writes every change through to the Store.
+type Store interface {
+	Put(t task.Task) error
+	List() ([]task.Task, error)
+	Close() error
+}
+
+// MemoryStore keeps the worker's task records in memory; they're lost when the worker exits.
+type MemoryStore struct {
+	mu    sync.Mutex
+	tasks map[uuid.UUID]task.Task
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tasks: make(map[uuid.UUID]task.Task)}
+}
+
+func (s *MemoryStore) Put(t task.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks[t.ID] = t
+	return nil
+}
+
+func (s *MemoryStore) List() ([]task.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tasks := make([]task.Task, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+var tasksBucket = []byte("tasks")
+
+// BoltStore keeps the worker's task records, as JSON, in an embedded bbolt database file.
+type BoltStore struct {
+	Db *bolt.DB
+}
+
+// NewBoltStore opens, or creates, the database file at path.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(tasksBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to create bucket %s: %w", tasksBucket, err)
+	}
+
+	return &BoltStore{Db: db}, nil
+}
+
+func (s *BoltStore) Put(t task.Task) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("unable to marshal task %v: %w", t.ID, err)
+	}
+
+	return s.Db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(tasksBucket).Put([]byte(t.ID.String()), data)
+	})
+}
+
+func (s *BoltStore) List() ([]task.Task, error) {
+	var tasks []task.Task
+	err := s.Db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(tasksBucket).ForEach(func(k, v []byte) error {
+			var t task.Task
+			if err := json.Unmarshal(v, &t); err != nil {
+				return fmt.Errorf("unable to unmarshal task %s: %w", k, err)
+			}
+			tasks = append(tasks, t)
+			return nil
+		})
+	})
+	return tasks, err
+}
+
+func (s *BoltStore) Close() error {
+	return s.Db.Close()
+}
@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"orchestra/task"
+	"sort"
+)
+
+// Volumes returns the volumes the worker's runtimes created for the Volume mounts of tasks, sorted by name.
+func (w *Worker) Volumes() ([]task.VolumeInfo, error) {
+	volumes := []task.VolumeInfo{}
+	for _, vm := range w.volumeManagers() {
+		found, err := vm.Volumes(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("unable to list volumes: %w", err)
+		}
+		volumes = append(volumes, found...)
+	}
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].Name < volumes[j].Name })
+	return volumes, nil
+}
+
+// RemoveVolume removes a volume the worker's runtimes created for tasks. It returns an error wrapping
+// task.ErrVolumeNotFound if none of them has it and task.ErrVolumeInUse if a container still uses it.
+func (w *Worker) RemoveVolume(name string) error {
+	for _, vm := range w.volumeManagers() {
+		err := vm.RemoveVolume(context.Background(), name)
+		if !errors.Is(err, task.ErrVolumeNotFound) {
+			return err
+		}
+	}
+	return fmt.Errorf("%w: %s", task.ErrVolumeNotFound, name)
+}
+
+// volumeManagers returns the runtimes of the worker that manage volumes, each once.
+func (w *Worker) volumeManagers() []task.VolumeManager {
+	var managers []task.VolumeManager
+	seen := make(map[task.Runtime]bool)
+	for _, rt := range w.allRuntimes() {
+		if vm, ok := rt.(task.VolumeManager); ok && !seen[rt] {
+			seen[rt] = true
+			managers = append(managers, vm)
+		}
+	}
+	return managers
+}
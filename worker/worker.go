@@ -1,10 +1,13 @@
 package worker
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"orchestra/node"
 	"orchestra/task"
+	"sync"
 	"time"
 
 	"github.com/golang-collections/collections/queue"
@@ -29,19 +32,71 @@ import (
 // we’re using the worker’s datastore (db) to
 // represent the current state of tasks, while we’re using the worker’s queue to
 // represent the desired state of task
+//
+// Queue, Db, TaskCount and Stats are shared by the API handlers and the processing loops,
+// so they must only be used through the worker's methods once the worker is running.
 type Worker struct {
-	Name      string                   //
-	Queue     queue.Queue              //
-	Db        map[uuid.UUID]*task.Task // Db maps task identifiers (UUID) to their respective Task objects.
-	TaskCount int                      //
-	Stats *Stats
+	Name       string                   // Name identifies the worker to the manager.
+	Address    string                   // Address is the "host:port" on which the worker's API can be reached.
+	Manager    string                   // Manager is the "host:port" address of the manager the worker registers with.
+	Labels     map[string]string        // Labels describe the worker's node to the manager, e.g. "disk=ssd".
+	Reserved   node.Resources           // Reserved is the CPU, memory and disk of the node kept for the system rather than for tasks.
+	Overcommit node.Overcommit          // Overcommit is how far the manager may overcommit the CPU and memory of the node.
+	Queue      queue.Queue              //
+	Db         map[uuid.UUID]*task.Task // Db maps task identifiers (UUID) to their respective Task objects.
+	Store      Store                    // Store persists the records in Db so that the worker can pick them up again after a restart.
+	TaskCount  int                      //
+	Stats      *Stats
+	Runtime    task.Runtime            // Runtime runs the containers of the tasks that don't ask for a runtime by name.
+	Runtimes   map[string]task.Runtime // Runtimes maps the runtime names tasks may ask for through their RuntimeName to the runtimes.
+
+	HealthRestartBackoff    time.Duration // HealthRestartBackoff is how long a task restarted for being unhealthy waits before it's restarted again for it.
+	MaxHealthRestartBackoff time.Duration // MaxHealthRestartBackoff caps that wait, which doubles with every restart.
+
+	mu        sync.Mutex                 // mu guards Queue, Db, TaskCount, Stats and health.
+	lifecycle sync.Mutex                 // lifecycle is held while the containers of a task are started or stopped, so that a stop and a health restart don't interleave.
+	notify    chan struct{}              // notify wakes ProcessTasks up when a task is added to the Queue.
+	health    map[uuid.UUID]*healthState // health holds the state of the health checks of the running tasks.
+}
+
+// New creates a worker that keeps its task records in the given store and runs its tasks with the given runtime.
+func New(name, address, manager string, store Store, runtime task.Runtime) *Worker {
+	return &Worker{
+		Name:    name,
+		Address: address,
+		Manager: manager,
+		Queue:   *queue.New(),
+		Db:      make(map[uuid.UUID]*task.Task),
+		Store:   store,
+		Runtime: runtime,
+		notify:  make(chan struct{}, 1),
+		health:  make(map[uuid.UUID]*healthState),
+	}
+}
+
+// ProcessTasks runs the tasks added to the queue as soon as they're added.
+func (w *Worker) ProcessTasks() {
+	for range w.notify {
+		for w.queueLen() != 0 {
+			result := w.RunTask()
+			if result.Error != nil {
+				log.Printf("Error running task: %s", result.Error)
+			}
+		}
+	}
 }
 
 // RunTask starts or stop a task based on its current state
 func (w *Worker) RunTask() task.DockerResult {
+	w.lifecycle.Lock()
+	defer w.lifecycle.Unlock()
+
 	//1. Pull a task of the queue.
+	w.mu.Lock()
+	defer w.UpdateTaskCount()
 	elem := w.Queue.Dequeue()
 	if elem == nil {
+		w.mu.Unlock()
 		log.Println("No task in the queue")
 		return task.DockerResult{Error: nil}
 	}
@@ -49,6 +104,7 @@ func (w *Worker) RunTask() task.DockerResult {
 	// 2. Convert it from an interface to a task.Task type.
 	queuedTask, ok := elem.(task.Task) // convert the interface to of type task.
 	if !ok {
+		w.mu.Unlock()
 		log.Println("Element is not of type task.Task{}")
 		return task.DockerResult{Error: errors.New("element is not of type task.Task{}")}
 	}
@@ -57,110 +113,226 @@ func (w *Worker) RunTask() task.DockerResult {
 	persistedTask, found := w.Db[queuedTask.ID]
 	if !found {
 		log.Printf("Task %s is not in the worker's Db", queuedTask.ID)
-	}
-
-	if persistedTask == nil {
 		persistedTask = &queuedTask
-		w.Db[queuedTask.ID] = &queuedTask
+		w.saveTaskLocked(&queuedTask)
 	}
+	previousState := persistedTask.State
+	// a stop queued before the task was started carries no container, so it's stopped by its record's.
+	containerID := persistedTask.Runtime.ContainerId
+	w.mu.Unlock()
 
 	// 4. Check if the state transition is valid.
 	var result task.DockerResult
-	if task.ValidateStateTransition(persistedTask.State, queuedTask.State) {
+	if task.ValidateStateTransition(previousState, queuedTask.State) {
 		switch queuedTask.State {
 		case task.Scheduled: // 5. If the task from the queue is in a state Scheduled, call StartTask.
 			result = w.StartTask(queuedTask)
 		case task.Completed: // 6. If the task from the queue is in a state Completed, call StopTask.
+			queuedTask.Runtime.ContainerId = containerID
 			result = w.StopTask(queuedTask)
 		default:
 			result.Error = errors.New("invalid state transition")
 		}
 	} else {
 		// 7. Else there is an invalid transition, so return an error.
-		err := fmt.Errorf("invalid state transition from %v to %v", persistedTask.State, queuedTask.State)
+		err := fmt.Errorf("invalid state transition from %v to %v", previousState, queuedTask.State)
 		result.Error = err
 	}
 
 	return result
 }
 
+// AddTask queues a task and wakes ProcessTasks up to run it. A task the worker doesn't know yet is recorded
+// as Scheduled straight away, so that it can be stopped before it's started.
 func (w *Worker) AddTask(t task.Task) {
+	w.mu.Lock()
+	if _, known := w.Db[t.ID]; !known && t.State == task.Scheduled {
+		scheduled := t
+		w.saveTaskLocked(&scheduled)
+	}
 	w.Queue.Enqueue(t)
+	w.mu.Unlock()
 	w.UpdateTaskCount()
+
+	select {
+	case w.notify <- struct{}{}:
+	default: // ProcessTasks has already been notified and will drain the queue.
+	}
 }
 
 func (w *Worker) StartTask(t task.Task) task.DockerResult {
+	ctx := context.Background()
 	t.StartTime = time.Now().UTC()
+	// nothing is known yet about the health of the new container; the history of the previous ones is kept.
+	t.Health = ""
 
-	config := task.NewConfig(&t)
-	d := task.NewDocker(config)
+	rt, err := w.runtimeFor(t.RuntimeName)
+	if err == nil {
+		err = t.Validate()
+	}
+	if err != nil {
+		log.Printf("Unable to run task %v: %v", t.ID, err)
+		t.State = task.Failed
+		w.saveTask(&t)
+		return task.DockerResult{Error: err, Action: task.CREATE, Result: task.FAILURE}
+	}
+
+	if t.Runtime.ContainerId != "" {
+		// the task is being restarted, and its previous container holds the name the new one needs.
+		if err := rt.Remove(ctx, t.Runtime.ContainerId); err != nil && !errors.Is(err, task.ErrNotFound) {
+			log.Printf("Error removing the previous container %s of task %v: %v", t.Runtime.ContainerId, t.ID, err)
+		}
+	}
 
-	result := d.Run()
+	config := task.NewConfig(&t)
+	result := task.Run(ctx, rt, config)
 	if result.Error != nil {
-		log.Printf("Error running container: %v: %v", d.ContainerId, result.Error)
+		log.Printf("Error running container: %v: %v", result.ContainerId, result.Error)
 		t.State = task.Failed
-		w.Db[t.ID] = &t
+		w.saveTask(&t)
 		return result
 	}
 
-	t.FinishTime = time.Now().UTC()
 	t.Runtime.ContainerId = result.ContainerId
 	t.State = task.Running
-	w.Db[t.ID] = &t
+	w.saveTask(&t)
 
-	log.Printf("Started and ran container %v for task %v", d.ContainerId, t.Name)
+	log.Printf("Started and ran container %v for task %v", result.ContainerId, t.Name)
 
 	return result
 }
 
 // StopTask similar to 'docker stop <container_id>'
 func (w *Worker) StopTask(t task.Task) task.DockerResult {
-	//1. Create an instance of the Docker struct that allows us to talk to the Docker daemon using the Docker SDK.
-	config := task.NewConfig(&t)
-	d := task.NewDocker(config)
-
-	// 2. Call the Stop() method on the Docker struct.
-	result := d.Stop(t.Runtime.ContainerId)
-	// 3. Check if there were any errors in stopping the task.
+	// 1. Call Stop() with the task's runtime, which stops and removes the container.
+	rt, err := w.runtimeFor(t.RuntimeName)
+	if err != nil {
+		log.Printf("Unable to stop task %v: %v", t.ID, err)
+		return task.DockerResult{Error: err, Action: task.STOP, Result: task.FAILURE}
+	}
+	result := task.Stop(context.Background(), rt, t.Runtime.ContainerId)
+	// 2. Check if there were any errors in stopping the task.
 	if result.Error != nil {
-		log.Printf("Error stopping container: %v: %v", d.ContainerId, result.Error)
+		log.Printf("Error stopping container: %v: %v", t.Runtime.ContainerId, result.Error)
 		t.State = task.Failed
-		w.Db[t.ID] = &t
+		w.saveTask(&t)
 		return result
 	}
 
-	// 4. Update the FinishTime field on the task t.
+	// 3. Update the FinishTime field on the task t.
 	t.FinishTime = time.Now().UTC()
-	t.Runtime.ContainerId = result.ContainerId
 	t.State = task.Completed
-	// 5. Save the updated task t to the worker’s Db field.
-	w.Db[t.ID] = &t
+	// 4. Save the updated task t to the worker’s Db field.
+	w.saveTask(&t)
 
-	// 6. Print an informative message and return the result of the operation
-	log.Printf("Stopped and removed container %v for task %v", d.ContainerId, t.Name)
+	// 5. Print an informative message and return the result of the operation
+	log.Printf("Stopped and removed container %v for task %v", t.Runtime.ContainerId, t.Name)
 
 	return result
 }
 
-// GetTasks this fetches all the tasks in the workers store.
-func (w *Worker) GetTasks() []*task.Task {
-	tasks := make([]*task.Task, 0)
+// runtimeFor returns the runtime registered under name, or the default Runtime when name is empty.
+func (w *Worker) runtimeFor(name string) (task.Runtime, error) {
+	if name == "" {
+		return w.Runtime, nil
+	}
+	rt, ok := w.Runtimes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown runtime %q", name)
+	}
+	return rt, nil
+}
+
+// allRuntimes returns each of the worker's runtimes once, under the name tasks use to ask for it;
+// the default Runtime is under the empty name unless it's also registered under a name of its own.
+func (w *Worker) allRuntimes() map[string]task.Runtime {
+	runtimes := make(map[string]task.Runtime)
+	isNamed := false
+	for name, rt := range w.Runtimes {
+		runtimes[name] = rt
+		isNamed = isNamed || rt == w.Runtime
+	}
+	if !isNamed && w.Runtime != nil {
+		runtimes[""] = w.Runtime
+	}
+	return runtimes
+}
+
+// saveTask records the task in the worker's Db and writes it through to the Store.
+func (w *Worker) saveTask(t *task.Task) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.saveTaskLocked(t)
+}
+
+// saveTaskLocked is saveTask for callers that already hold w.mu.
+func (w *Worker) saveTaskLocked(t *task.Task) {
+	w.Db[t.ID] = t
+	if err := w.Store.Put(*t); err != nil {
+		log.Printf("Error saving task %v: %v", t.ID, err)
+	}
+}
+
+// GetTasks this fetches a copy of all the tasks in the workers store.
+func (w *Worker) GetTasks() []task.Task {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	tasks := make([]task.Task, 0, len(w.Db))
 	for _, t := range w.Db {
-		tasks = append(tasks, t)
+		tasks = append(tasks, *t)
 	}
 	return tasks
 }
 
+// GetTask returns a copy of the task with the given ID from the workers store.
+func (w *Worker) GetTask(id uuid.UUID) (task.Task, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	t, ok := w.Db[id]
+	if !ok {
+		return task.Task{}, false
+	}
+	return *t, true
+}
 
 func (w *Worker) CollectStats() {
 	for {
 		log.Println("Collecting stats")
-		w.Stats = GetStats()
+		stats := GetStats()
+		w.mu.Lock()
+		stats.TaskCount = w.TaskCount
+		w.Stats = stats
+		w.mu.Unlock()
 		time.Sleep(15 * time.Second) // collect stats every 15 seconds.
 	}
 }
 
+// CurrentStats returns a copy of the stats last collected by CollectStats, or nil if none were collected yet.
+func (w *Worker) CurrentStats() *Stats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.Stats == nil {
+		return nil
+	}
+	stats := *w.Stats
+	return &stats
+}
+
 func (w *Worker) UpdateTaskCount() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	w.TaskCount = w.Queue.Len()
-	w.Stats.TaskCount = w.TaskCount
+	if w.Stats != nil {
+		w.Stats.TaskCount = w.TaskCount
+	}
+}
+
+func (w *Worker) queueLen() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.Queue.Len()
 }
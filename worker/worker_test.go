@@ -0,0 +1,319 @@
+package worker
+
+import (
+	"errors"
+	"orchestra/task"
+	"orchestra/task/fake"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newTestWorker creates a worker backed by a MemoryStore and a fake runtime.
+func newTestWorker() (*Worker, *fake.Runtime) {
+	rt := fake.NewRuntime()
+	return New("test-worker", "127.0.0.1:0", "", NewMemoryStore(), rt), rt
+}
+
+func newTask(state task.State) task.Task {
+	id := uuid.New()
+	return task.Task{ID: id, Name: "test-" + id.String(), State: state, Image: "alpine:3"}
+}
+
+// mustGetTask returns the worker's record of the task, failing the test if it has none.
+func mustGetTask(tb testing.TB, w *Worker, id uuid.UUID) task.Task {
+	tb.Helper()
+	t, ok := w.GetTask(id)
+	if !ok {
+		tb.Fatalf("task %v is not in the worker's Db", id)
+	}
+	return t
+}
+
+func TestRunTaskStartsScheduledTask(t *testing.T) {
+	w, rt := newTestWorker()
+	queued := newTask(task.Scheduled)
+	queued.Cmd = []string{"sleep", "60"}
+	w.AddTask(queued)
+
+	result := w.RunTask()
+	if result.Error != nil {
+		t.Fatalf("RunTask() error = %v", result.Error)
+	}
+
+	got := mustGetTask(t, w, queued.ID)
+	if got.State != task.Running {
+		t.Errorf("task state = %v, want %v", got.State, task.Running)
+	}
+	if got.Runtime.ContainerId != result.ContainerId {
+		t.Errorf("task container = %q, want %q", got.Runtime.ContainerId, result.ContainerId)
+	}
+	config, ok := rt.Config(result.ContainerId)
+	if !ok {
+		t.Fatalf("container %s was not created", result.ContainerId)
+	}
+	if config.Image != queued.Image || strings.Join(config.Cmd, " ") != "sleep 60" {
+		t.Errorf("container config = %+v, want the task's image and command", config)
+	}
+	if config.Labels[task.LabelTaskID] != queued.ID.String() {
+		t.Errorf("container label %s = %q, want %q", task.LabelTaskID, config.Labels[task.LabelTaskID], queued.ID)
+	}
+	if w.TaskCount != 0 {
+		t.Errorf("TaskCount = %d after the queue was drained", w.TaskCount)
+	}
+}
+
+func TestRunTaskPullFailure(t *testing.T) {
+	w, rt := newTestWorker()
+	queued := newTask(task.Scheduled)
+	rt.FailPull(queued.Image, errors.New("manifest unknown"))
+	w.AddTask(queued)
+
+	result := w.RunTask()
+	if result.Error == nil || result.Action != task.PULL {
+		t.Fatalf("RunTask() = %+v, want a pull failure", result)
+	}
+	if got := mustGetTask(t, w, queued.ID); got.State != task.Failed {
+		t.Errorf("task state = %v, want %v", got.State, task.Failed)
+	}
+	if containers := rt.Containers(); len(containers) != 0 {
+		t.Errorf("runtime has %d containers after a failed pull", len(containers))
+	}
+}
+
+func TestRunTaskCrashOnStart(t *testing.T) {
+	w, rt := newTestWorker()
+	queued := newTask(task.Scheduled)
+	rt.FailNext(fake.Start, errors.New("exec format error"))
+	w.AddTask(queued)
+
+	result := w.RunTask()
+	if result.Error == nil || result.Action != task.START {
+		t.Fatalf("RunTask() = %+v, want a start failure", result)
+	}
+	if got := mustGetTask(t, w, queued.ID); got.State != task.Failed {
+		t.Errorf("task state = %v, want %v", got.State, task.Failed)
+	}
+}
+
+func TestUpdateTasksRecordsCrash(t *testing.T) {
+	w, rt := newTestWorker()
+	queued := newTask(task.Scheduled)
+	w.AddTask(queued)
+	result := w.RunTask()
+	if result.Error != nil {
+		t.Fatalf("RunTask() error = %v", result.Error)
+	}
+
+	// the container dies right after it started.
+	if err := rt.Crash(result.ContainerId); err != nil {
+		t.Fatal(err)
+	}
+	w.UpdateTasks()
+
+	if got := mustGetTask(t, w, queued.ID); got.State != task.Failed {
+		t.Errorf("task state = %v after its container crashed, want %v", got.State, task.Failed)
+	}
+}
+
+func TestUpdateTasksRecordsExit(t *testing.T) {
+	tests := []struct {
+		code int
+		want task.State
+	}{
+		{0, task.Completed},
+		{1, task.Failed},
+	}
+
+	for _, tt := range tests {
+		w, rt := newTestWorker()
+		queued := newTask(task.Scheduled)
+		w.AddTask(queued)
+		result := w.RunTask()
+		if result.Error != nil {
+			t.Fatalf("RunTask() error = %v", result.Error)
+		}
+
+		if err := rt.Exit(result.ContainerId, tt.code); err != nil {
+			t.Fatal(err)
+		}
+		w.UpdateTasks()
+
+		got := mustGetTask(t, w, queued.ID)
+		if got.State != tt.want {
+			t.Errorf("task state = %v after its container exited with %d, want %v", got.State, tt.code, tt.want)
+		}
+		if got.FinishTime.IsZero() {
+			t.Errorf("task has no FinishTime after its container exited with %d", tt.code)
+		}
+	}
+}
+
+func TestUpdateTasksKeepsRestartingContainerRunning(t *testing.T) {
+	w, rt := newTestWorker()
+	queued := newTask(task.Scheduled)
+	queued.RestartPolicy = "always"
+	w.AddTask(queued)
+	result := w.RunTask()
+	if result.Error != nil {
+		t.Fatalf("RunTask() error = %v", result.Error)
+	}
+	if config, _ := rt.Config(result.ContainerId); config.RestartPolicy != "always" {
+		t.Errorf("container created with restart policy %q, want the task's", config.RestartPolicy)
+	}
+
+	if err := rt.Restarting(result.ContainerId, 1); err != nil {
+		t.Fatal(err)
+	}
+	w.UpdateTasks()
+
+	if got := mustGetTask(t, w, queued.ID); got.State != task.Running {
+		t.Errorf("task state = %v while the runtime restarts its container, want %v", got.State, task.Running)
+	}
+}
+
+func TestUpdateTasksRecordsVanishedContainer(t *testing.T) {
+	w, rt := newTestWorker()
+	queued := newTask(task.Scheduled)
+	w.AddTask(queued)
+	result := w.RunTask()
+	if result.Error != nil {
+		t.Fatalf("RunTask() error = %v", result.Error)
+	}
+
+	rt.Vanish(result.ContainerId)
+	w.UpdateTasks()
+
+	if got := mustGetTask(t, w, queued.ID); got.State != task.Failed {
+		t.Errorf("task state = %v after its container vanished, want %v", got.State, task.Failed)
+	}
+}
+
+func TestRunTaskLatency(t *testing.T) {
+	w, rt := newTestWorker()
+	rt.Latency = 10 * time.Millisecond
+	queued := newTask(task.Scheduled)
+	w.AddTask(queued)
+
+	start := time.Now()
+	result := w.RunTask()
+	elapsed := time.Since(start)
+	if result.Error != nil {
+		t.Fatalf("RunTask() error = %v", result.Error)
+	}
+
+	// pulling, creating, starting and reading the logs each take the latency.
+	if elapsed < 4*rt.Latency {
+		t.Errorf("RunTask() took %v, want at least %v", elapsed, 4*rt.Latency)
+	}
+	if got := mustGetTask(t, w, queued.ID); got.State != task.Running {
+		t.Errorf("task state = %v, want %v", got.State, task.Running)
+	}
+}
+
+func TestRunTaskStopsRunningTask(t *testing.T) {
+	w, rt := newTestWorker()
+	queued := newTask(task.Scheduled)
+	w.AddTask(queued)
+	result := w.RunTask()
+	if result.Error != nil {
+		t.Fatalf("RunTask() error = %v", result.Error)
+	}
+
+	stopped := mustGetTask(t, w, queued.ID)
+	stopped.State = task.Completed
+	w.AddTask(stopped)
+	if result := w.RunTask(); result.Error != nil {
+		t.Fatalf("RunTask() error = %v", result.Error)
+	}
+
+	if got := mustGetTask(t, w, queued.ID); got.State != task.Completed {
+		t.Errorf("task state = %v, want %v", got.State, task.Completed)
+	}
+	if containers := rt.Containers(); len(containers) != 0 {
+		t.Errorf("runtime has %d containers after the task was stopped", len(containers))
+	}
+}
+
+func TestRunTaskRestartsFinishedTask(t *testing.T) {
+	w, rt := newTestWorker()
+	queued := newTask(task.Scheduled)
+	w.AddTask(queued)
+	first := w.RunTask()
+	if first.Error != nil {
+		t.Fatalf("RunTask() error = %v", first.Error)
+	}
+	if err := rt.Exit(first.ContainerId, 1); err != nil {
+		t.Fatal(err)
+	}
+	w.UpdateTasks()
+
+	// Failed to Scheduled is how the manager restarts a task.
+	restarted := mustGetTask(t, w, queued.ID)
+	restarted.State = task.Scheduled
+	w.AddTask(restarted)
+	second := w.RunTask()
+	if second.Error != nil {
+		t.Fatalf("RunTask() error = %v", second.Error)
+	}
+
+	got := mustGetTask(t, w, queued.ID)
+	if got.State != task.Running || got.Runtime.ContainerId != second.ContainerId {
+		t.Errorf("task = %v in container %s, want running in %s", got.State, got.Runtime.ContainerId, second.ContainerId)
+	}
+	if _, ok := rt.Config(first.ContainerId); ok {
+		t.Errorf("previous container %s was not removed", first.ContainerId)
+	}
+}
+
+func TestRunTaskRejectsInvalidTransition(t *testing.T) {
+	w, _ := newTestWorker()
+	queued := newTask(task.Scheduled)
+	w.AddTask(queued)
+	if result := w.RunTask(); result.Error != nil {
+		t.Fatalf("RunTask() error = %v", result.Error)
+	}
+	stop := mustGetTask(t, w, queued.ID)
+	stop.State = task.Completed
+	w.AddTask(stop)
+	if result := w.RunTask(); result.Error != nil {
+		t.Fatalf("RunTask() error = %v", result.Error)
+	}
+
+	// a completed task can't be stopped again.
+	w.AddTask(stop)
+	result := w.RunTask()
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "invalid state transition") {
+		t.Fatalf("RunTask() error = %v, want an invalid state transition", result.Error)
+	}
+	if got := mustGetTask(t, w, queued.ID); got.State != task.Completed {
+		t.Errorf("task state = %v, want it left %v", got.State, task.Completed)
+	}
+}
+
+func TestRunTaskRejectsInvalidTask(t *testing.T) {
+	w, rt := newTestWorker()
+	queued := newTask(task.Scheduled)
+	queued.RuntimeName = "lxc"
+	w.AddTask(queued)
+
+	result := w.RunTask()
+	if result.Error == nil {
+		t.Fatal("RunTask() error = nil for a task asking for an unknown runtime")
+	}
+	if got := mustGetTask(t, w, queued.ID); got.State != task.Failed {
+		t.Errorf("task state = %v, want %v", got.State, task.Failed)
+	}
+	if calls := rt.Calls(); len(calls) != 0 {
+		t.Errorf("runtime got %d calls for a task it can't run", len(calls))
+	}
+}
+
+func TestRunTaskWithEmptyQueue(t *testing.T) {
+	w, _ := newTestWorker()
+	if result := w.RunTask(); result.Error != nil {
+		t.Errorf("RunTask() error = %v with nothing queued", result.Error)
+	}
+}